@@ -0,0 +1,172 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// Features of the Bbox whose endpoints are only requested when supported.
+const (
+	featureXDsl      = "xdsl"
+	featureRadio6GHz = "radio_6ghz"
+	featureUSB       = "usb"
+	featureIPTV      = "iptv"
+)
+
+// The probe of the capabilities is retried after capsRetryMin, then twice as
+// late after each failure, up to capsRetryMax.
+const (
+	capsRetryMin = time.Minute
+	capsRetryMax = time.Hour
+)
+
+// Capabilities are the features of the model and firmware of the Bbox.
+// The endpoints of the missing features are not requested.
+type Capabilities struct {
+	Model     string
+	Firmware  string
+	XDsl      bool
+	Ftth      bool
+	Radio6GHz bool
+	USB       bool
+	IPTV      bool
+}
+
+// has returns true if the Bbox has the feature.
+func (caps *Capabilities) has(feature string) bool {
+	switch feature {
+	case featureXDsl:
+		return caps.XDsl
+	case featureRadio6GHz:
+		return caps.Radio6GHz
+	case featureUSB:
+		return caps.USB
+	case featureIPTV:
+		return caps.IPTV
+	}
+	return true
+}
+
+// GetCapabilities returns the features of the Bbox. They are probed from
+// /device and /summary once per session. A failed probe is not retried
+// before a backoff delay.
+func (client *Client) GetCapabilities() (*Capabilities, error) {
+	client.capsMu.Lock()
+	defer client.capsMu.Unlock()
+	if client.caps != nil {
+		return client.caps, nil
+	}
+	if client.capsErr != nil && time.Now().Before(client.capsRetry) {
+		return nil, client.capsErr
+	}
+
+	caps, err := client.probeCapabilities()
+	if err != nil {
+		backoff := capsRetryMax
+		if client.capsFailures < 6 {
+			backoff = capsRetryMin << uint(client.capsFailures)
+		}
+		client.capsFailures++
+		client.capsErr = err
+		client.capsRetry = time.Now().Add(backoff)
+		return nil, err
+	}
+	level.Info(client.logger).Log("msg", "Bbox capabilities", "capabilities", fmt.Sprintf("%+v", *caps))
+	client.caps = caps
+	client.capsErr = nil
+	client.capsFailures = 0
+	return caps, nil
+}
+
+func (client *Client) probeCapabilities() (*Capabilities, error) {
+	informations, err := client.getDeviceInformations()
+	if err != nil {
+		return nil, err
+	}
+	if len(informations) == 0 {
+		return nil, fmt.Errorf("no device informations")
+	}
+	device := informations[0].Device
+	caps := &Capabilities{
+		Model:    device.ModelName,
+		Firmware: device.Running.Version,
+		XDsl:     device.Using.ADSL == 1 || device.Using.VDSL == 1,
+		Ftth:     device.Using.FTTH == 1,
+		// Unknown without the summary: the endpoints are requested.
+		USB:  true,
+		IPTV: true,
+	}
+	if !caps.XDsl && !caps.Ftth {
+		// Older firmwares don't tell the line: both are requested.
+		caps.XDsl = true
+	}
+
+	summary, err := client.GetSummary()
+	if err != nil {
+		level.Warn(client.logger).Log("msg", "Summary not available, assume all features", "err", err)
+		return caps, nil
+	}
+	for band := range summary.Wireless.Radio {
+		if band == "6" {
+			caps.Radio6GHz = true
+		}
+	}
+	caps.USB = summary.Usb != nil
+	caps.IPTV = summary.Iptv != nil
+	return caps, nil
+}
+
+// supports returns true if the Bbox has the feature. When its features are
+// unknown, the feature is supported until its endpoint is not found.
+func (client *Client) supports(feature string) bool {
+	client.capsMu.Lock()
+	defer client.capsMu.Unlock()
+	if client.unsupported[feature] {
+		return false
+	}
+	if client.caps == nil {
+		return true
+	}
+	return client.caps.has(feature)
+}
+
+// checkSupported records that the Bbox doesn't have the feature when the
+// request of its endpoint failed with ErrNotSupported, so the endpoint is
+// not requested again in the session.
+func (client *Client) checkSupported(feature string, err error) {
+	if !errors.Is(err, ErrNotSupported) {
+		return
+	}
+	level.Info(client.logger).Log("msg", "Feature not supported by the Bbox", "feature", feature)
+	client.capsMu.Lock()
+	defer client.capsMu.Unlock()
+	client.unsupported[feature] = true
+}
+
+// resetCapabilities forgets the features of the Bbox, probed again in the
+// next session: the firmware may have been upgraded.
+func (client *Client) resetCapabilities() {
+	client.capsMu.Lock()
+	defer client.capsMu.Unlock()
+	client.caps = nil
+	client.capsErr = nil
+	client.capsFailures = 0
+	client.unsupported = map[string]bool{}
+}
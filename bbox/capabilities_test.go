@@ -0,0 +1,178 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+// The responses recorded for the tests of the exporter.
+const exporterFixturesDir = "../exporter/testdata/bbox"
+
+// routeTransport answers the requests of the given paths of the API, and the
+// others with the recorded responses. It counts the requests of each path.
+type routeTransport struct {
+	routes map[string]fixtureTransport
+	next   http.RoundTripper
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (t *routeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	path := strings.TrimPrefix(request.URL.Path, apiVersion)
+	t.mu.Lock()
+	t.requests[path]++
+	t.mu.Unlock()
+	if route, ok := t.routes[path]; ok {
+		return route.RoundTrip(request)
+	}
+	return t.next.RoundTrip(request)
+}
+
+func (t *routeTransport) count(path string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.requests[path]
+}
+
+func newRouteClient(t *testing.T, routes map[string]fixtureTransport) (*Client, *routeTransport) {
+	client, err := NewClient("https://bbox.test", "password", log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &routeTransport{
+		routes:   routes,
+		next:     NewReplayTransport(exporterFixturesDir),
+		requests: map[string]int{},
+	}
+	client.SetTransport(transport)
+	return client, transport
+}
+
+func okResponse(body string) fixtureTransport {
+	return fixtureTransport{status: http.StatusOK, body: body}
+}
+
+var notFound = fixtureTransport{status: http.StatusNotFound, body: `{}`}
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		device  fixtureTransport
+		summary fixtureTransport
+		want    Capabilities
+	}{
+		{
+			name:    "fiber with 6 GHz radio, USB and IPTV",
+			device:  okResponse(`[{"device":{"modelname":"Bbox Ultym","running":{"version":"23.1.2"},"using":{"ftth":1}}}]`),
+			summary: okResponse(`[{"wireless":{"radio":{"24":{},"5":{},"6":{}}},"usb":{"printer":[]},"iptv":[]}]`),
+			want:    Capabilities{Model: "Bbox Ultym", Firmware: "23.1.2", Ftth: true, Radio6GHz: true, USB: true, IPTV: true},
+		},
+		{
+			name:    "xDsl without USB nor IPTV",
+			device:  okResponse(`[{"device":{"modelname":"Bbox Fast 5330b","running":{"version":"20.2.10"},"using":{"vdsl":1}}}]`),
+			summary: okResponse(`[{"wireless":{"radio":{"24":{},"5":{}}}}]`),
+			want:    Capabilities{Model: "Bbox Fast 5330b", Firmware: "20.2.10", XDsl: true},
+		},
+		{
+			name:    "unknown line",
+			device:  okResponse(`[{"device":{"modelname":"Bbox 2","running":{"version":"10.0.0"}}}]`),
+			summary: okResponse(`[{}]`),
+			want:    Capabilities{Model: "Bbox 2", Firmware: "10.0.0", XDsl: true},
+		},
+		{
+			name:    "without summary",
+			device:  okResponse(`[{"device":{"modelname":"Bbox 2","running":{"version":"10.0.0"},"using":{"adsl":1}}}]`),
+			summary: notFound,
+			want:    Capabilities{Model: "Bbox 2", Firmware: "10.0.0", XDsl: true, USB: true, IPTV: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := newRouteClient(t, map[string]fixtureTransport{
+				"/device":  test.device,
+				"/summary": test.summary,
+			})
+			caps, err := client.GetCapabilities()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*caps, test.want) {
+				t.Errorf("capabilities = %+v, want %+v", *caps, test.want)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesGating(t *testing.T) {
+	client, transport := newRouteClient(t, map[string]fixtureTransport{
+		"/summary": okResponse(`[{"wireless":{"radio":{"24":{},"5":{}}}}]`),
+	})
+	if _, err := client.GetMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/wireless/6/stats", "/usb", "/iptv"} {
+		if n := transport.count(path); n != 0 {
+			t.Errorf("%s requested %d times, want 0", path, n)
+		}
+	}
+	if n := transport.count("/wireless/5/stats"); n != 1 {
+		t.Errorf("/wireless/5/stats requested %d times, want 1", n)
+	}
+}
+
+func TestCapabilitiesProbeFailure(t *testing.T) {
+	client, transport := newRouteClient(t, map[string]fixtureTransport{
+		"/device":           okResponse(`[]`),
+		"/wireless/6/stats": notFound,
+		"/usb":              notFound,
+		"/iptv":             notFound,
+	})
+	if _, err := client.GetCapabilities(); err == nil {
+		t.Fatal("GetCapabilities succeeded without device informations")
+	}
+	if _, err := client.GetCapabilities(); err == nil {
+		t.Fatal("GetCapabilities succeeded without device informations")
+	}
+	if n := transport.count("/device"); n != 1 {
+		t.Errorf("/device requested %d times before the retry delay, want 1", n)
+	}
+
+	// The features are unknown: the endpoints are requested until they
+	// are not found.
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetMetrics(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{"/wireless/6/stats", "/usb", "/iptv"} {
+		if n := transport.count(path); n != 1 {
+			t.Errorf("%s requested %d times, want 1", path, n)
+		}
+	}
+
+	// A new session probes the features again.
+	client.resetCapabilities()
+	if !client.supports(featureUSB) {
+		t.Error("USB not supported after a new session")
+	}
+}
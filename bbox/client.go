@@ -18,12 +18,15 @@ import (
 	// "encoding/json"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 
 	// "io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
@@ -45,21 +48,40 @@ const (
 
 // Metrics define Bbox Prometheus metrics
 type Metrics struct {
-	Device    DeviceMetrics   `json:"device"`
-	Wan       WanMetrics      `json:"wan"`
-	Lan       LanMetrics      `json:"lan"`
-	DNS       DNSMetrics      `json:"dns"`
-	Services  ServicesMetrics `json:"services"`
-	FtthState string          `json:"ftth_state"`
-	Wireless  WirelessMetrics `json:"wireless"`
-	IPTV      IPTVMetrics     `json:"iptv"`
+	Device          DeviceMetrics          `json:"device"`
+	Wan             WanMetrics             `json:"wan"`
+	Lan             LanMetrics             `json:"lan"`
+	DNS             DNSMetrics             `json:"dns"`
+	Services        ServicesMetrics        `json:"services"`
+	Wireless        WirelessMetrics        `json:"wireless"`
+	IPTV            IPTVMetrics            `json:"iptv"`
+	ParentalControl ParentalControlMetrics `json:"parental_control"`
+	USB             USBMetrics             `json:"usb"`
+	Hotspot         HotspotMetrics         `json:"hotspot"`
+	DynDNS          DynDNSMetrics          `json:"dyndns"`
+	Capabilities    *Capabilities          `json:"capabilities"`
 }
 
 type Client struct {
-	url      string
-	cookies  []*http.Cookie
-	password string
-	logger   log.Logger
+	url       string
+	mu        sync.RWMutex
+	cookies   []*http.Cookie
+	password  string
+	transport http.RoundTripper
+	logger    log.Logger
+
+	decodeMu     sync.Mutex
+	decodeErrors map[DecodeError]float64
+
+	capsMu sync.Mutex
+	caps   *Capabilities
+	// capsErr is the error of the last probe of the capabilities, retried
+	// after capsRetry.
+	capsErr      error
+	capsRetry    time.Time
+	capsFailures int
+	// unsupported are the features whose endpoints are not found.
+	unsupported map[string]bool
 }
 
 func NewClient(endpoint string, password string, logger log.Logger) (*Client, error) {
@@ -69,12 +91,25 @@ func NewClient(endpoint string, password string, logger log.Logger) (*Client, er
 	}
 	level.Info(logger).Log("msg", "Create client", "endpoint", endpoint)
 	return &Client{
-		url:      fmt.Sprintf("%s%s", url.String(), apiVersion),
-		password: password,
-		logger:   logger,
+		url:          fmt.Sprintf("%s%s", url.String(), apiVersion),
+		password:     password,
+		logger:       logger,
+		decodeErrors: map[DecodeError]float64{},
+		unsupported:  map[string]bool{},
 	}, nil
 }
 
+// SetTransport replaces the transport of the requests to the Bbox, e.g. to
+// record or replay the responses.
+func (client *Client) SetTransport(transport http.RoundTripper) {
+	client.transport = transport
+}
+
+// newHTTPClient returns the HTTP client of a request to the Bbox.
+func (client *Client) newHTTPClient() *http.Client {
+	return &http.Client{Timeout: time.Second * 10, Transport: client.transport}
+}
+
 // func (client *Client) setupHeaders(request *http.Request) {
 // 	request.Header.Add("Content-Type", mediaType)
 // 	request.Header.Add("X-Requested-By", application)
@@ -88,6 +123,12 @@ func (client *Client) GetMetrics() (*Metrics, error) {
 
 	var metrics Metrics
 
+	caps, err := client.GetCapabilities()
+	if err != nil {
+		level.Warn(client.logger).Log("msg", "Can't probe the Bbox capabilities", "err", err)
+	}
+	metrics.Capabilities = caps
+
 	deviceMetrics, err := client.getDeviceMetrics()
 	if err != nil {
 		return nil, fmt.Errorf("device metrics : %s", err)
@@ -130,44 +171,92 @@ func (client *Client) GetMetrics() (*Metrics, error) {
 	level.Info(client.logger).Log("msg", "DNS metrics", "metrics", dnsMetrics)
 	metrics.DNS = *dnsMetrics
 
-	iptv, err := client.getIPTVMetrics()
+	if client.supports(featureIPTV) {
+		iptv, err := client.getIPTVMetrics()
+		client.checkSupported(featureIPTV, err)
+		switch {
+		case errors.Is(err, ErrNotSupported):
+			level.Info(client.logger).Log("msg", "No IPTV on this Bbox, skip IPTV metrics")
+		case err != nil:
+			return nil, fmt.Errorf("iptv metrics %s", err)
+		default:
+			level.Info(client.logger).Log("msg", "IPTV metrics", "metrics", iptv)
+			metrics.IPTV = *iptv
+		}
+	} else {
+		level.Info(client.logger).Log("msg", "No IPTV on this Bbox, skip IPTV metrics")
+	}
+
+	parentalControl, err := client.getParentalControlMetrics()
 	if err != nil {
-		return nil, fmt.Errorf("iptv metrics %s", err)
+		// Optional: only the global state is available without it.
+		level.Warn(client.logger).Log("msg", "Parental control not available", "err", err)
+	} else {
+		level.Info(client.logger).Log("msg", "Parental control metrics", "metrics", parentalControl)
+		metrics.ParentalControl = *parentalControl
+	}
+
+	if client.supports(featureUSB) {
+		usb, err := client.getUSBMetrics()
+		client.checkSupported(featureUSB, err)
+		if err != nil {
+			level.Warn(client.logger).Log("msg", "USB not available", "err", err)
+		} else {
+			level.Info(client.logger).Log("msg", "USB metrics", "metrics", usb)
+			metrics.USB = *usb
+		}
+	} else {
+		level.Info(client.logger).Log("msg", "No USB port on this Bbox, skip USB metrics")
+	}
+
+	hotspot, err := client.getHotspotMetrics()
+	if err != nil {
+		level.Warn(client.logger).Log("msg", "Hotspot not available", "err", err)
+	} else {
+		level.Info(client.logger).Log("msg", "Hotspot metrics", "metrics", hotspot)
+		metrics.Hotspot = *hotspot
+	}
+
+	dyndns, err := client.getDynDNSMetrics()
+	if err != nil {
+		level.Warn(client.logger).Log("msg", "Dynamic DNS not available", "err", err)
+	} else {
+		level.Info(client.logger).Log("msg", "Dynamic DNS metrics", "metrics", dyndns)
+		metrics.DynDNS = *dyndns
 	}
-	level.Info(client.logger).Log("msg", "IPTV metrics", "metrics", fmt.Sprintf("%s", iptv))
-	metrics.IPTV = *iptv
 
 	return &metrics, nil
 }
 
 func (client *Client) Authenticate() error {
-	level.Info(client.logger).Log("msg", "Number of cookies", "code", len(client.cookies))
-	if len(client.cookies) != 0 {
+	previous := client.getCookies()
+	level.Info(client.logger).Log("msg", "Number of cookies", "code", len(previous))
+	if len(previous) != 0 {
 		url := fmt.Sprintf("%s/login", client.url)
-		httpClient := &http.Client{Timeout: time.Second * 10}
+		httpClient := client.newHTTPClient()
 		extend_request,err := http.NewRequest("PUT", url, nil)
 		if err != nil {
 			return err
 		}
-		for _, cookie := range client.cookies {
+		for _, cookie := range previous {
 			extend_request.AddCookie(cookie)
 		}
 		resp, err := httpClient.Do(extend_request)
-		level.Info(client.logger).Log("msg", "API login extend", "code", resp.StatusCode)
 		if err!= nil {
 			level.Error(client.logger).Log("msg", "API login extend", "api", err)
 		} else {
+			level.Info(client.logger).Log("msg", "API login extend", "code", resp.StatusCode)
 			cookies := resp.Cookies()
         		if len(resp.Cookies()) == 0 {
                 		return fmt.Errorf("can't retreive Cookie from API response")
         		}
-        		client.cookies = cookies
+        		client.setCookies(cookies)
 			return nil
 		}
 	}
 	request := fmt.Sprintf("%s/login", client.url)
 	level.Info(client.logger).Log("msg", "API request", "api", request)
-	resp, err := http.Post(
+	resp, err := client.newHTTPClient().Post(
 		request,
 		"application/x-www-form-urlencoded",
 		bytes.NewBuffer([]byte(fmt.Sprintf("password=%s", client.password))))
@@ -192,10 +281,24 @@ func (client *Client) Authenticate() error {
 	if len(resp.Cookies()) == 0 {
 		return fmt.Errorf("can't retreive Cookie from API response")
 	}
-	client.cookies = cookies
+	client.setCookies(cookies)
+	client.resetCapabilities()
 	return nil
 }
 
+// getCookies returns the session cookies, shared by concurrent requests.
+func (client *Client) getCookies() []*http.Cookie {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	return client.cookies
+}
+
+func (client *Client) setCookies(cookies []*http.Cookie) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.cookies = cookies
+}
+
 func (client *Client) apiRequest(request string, v interface{}) error {
 	url := fmt.Sprintf("%s%s", client.url, request)
 	level.Debug(client.logger).Log("msg", "API request", "request", url)
@@ -206,16 +309,16 @@ func (client *Client) apiRequest(request string, v interface{}) error {
 	}
 
 	req.Header.Set("Cache-Control", "no-cache")
-	if client.cookies != nil {
-		for _, cookie := range client.cookies {
+	if cookies := client.getCookies(); cookies != nil {
+		for _, cookie := range cookies {
 			req.AddCookie(cookie)
 		}
 	}
 
-	httpClient := &http.Client{Timeout: time.Second * 10}
+	httpClient := client.newHTTPClient()
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	defer resp.Body.Close()
@@ -226,10 +329,96 @@ func (client *Client) apiRequest(request string, v interface{}) error {
 	}
 
 	level.Debug(client.logger).Log("msg", "API response value", "request", url, "content", string(body))
-	dec := json.NewDecoder(bytes.NewBuffer(body))
-	if err := dec.Decode(v); err != nil {
+	if err := responseError("GET", request, resp.StatusCode, body); err != nil {
+		return err
+	}
+	fields, err := decodeLenient(body, v)
+	for _, field := range fields {
+		level.Warn(client.logger).Log("msg", "Can't decode field", "request", request, "field", field)
+		client.recordDecodeError(request, field)
+	}
+	if err != nil {
 		return err
 	}
 	level.Info(client.logger).Log("msg", "API entity", "api", fmt.Sprintf("%+v", v))
 	return nil
 }
+
+// GetRaw returns the response of any endpoint of the API, e.g. /device.
+func (client *Client) GetRaw(path string) (json.RawMessage, error) {
+	path = strings.TrimPrefix(path, apiVersion)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	var raw json.RawMessage
+	if err := client.apiRequest(path, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// getToken returns the token required by the write requests of the API.
+// See: https://api.bbox.fr/doc/apirouter/#api-Device-GetToken
+func (client *Client) getToken() (string, error) {
+	var tokens []struct {
+		Device struct {
+			Token string `json:"token"`
+		} `json:"device"`
+	}
+	if err := client.apiRequest("/device/token", &tokens); err != nil {
+		return "", err
+	}
+	if len(tokens) == 0 || tokens[0].Device.Token == "" {
+		return "", fmt.Errorf("can't retrieve token from API response")
+	}
+	return tokens[0].Device.Token, nil
+}
+
+// apiWriteRequest sends a write request to the API. It returns ErrNotSupported
+// when the firmware does not provide the request.
+func (client *Client) apiWriteRequest(method string, request string, values url.Values) error {
+	token, err := client.getToken()
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s%s?btoken=%s", client.url, request, url.QueryEscape(token))
+	level.Debug(client.logger).Log("msg", "API write request", "method", method, "request", request)
+
+	req, err := http.NewRequest(method, url, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range client.getCookies() {
+		req.AddCookie(cookie)
+	}
+
+	httpClient := client.newHTTPClient()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	level.Info(client.logger).Log("msg", "API write response", "method", method, "request", request, "code", resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return responseError(method, request, resp.StatusCode, body)
+}
+
+// responseError returns the error of a response of the API which is not
+// successful, or nil.
+func responseError(method string, request string, statusCode int, body []byte) error {
+	switch {
+	case statusCode == http.StatusNotFound || statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented:
+		return fmt.Errorf("%s %s: %w", method, request, ErrNotSupported)
+	case statusCode < 200 || statusCode >= 300:
+		var apiError APIError
+		if err := json.Unmarshal(body, &apiError); err != nil {
+			return fmt.Errorf("%s %s: HTTP %d", method, request, statusCode)
+		}
+		return fmt.Errorf("%s %s: HTTP %d: %+v", method, request, statusCode, apiError)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// DecodeError identifies a field of an endpoint which could not be decoded.
+type DecodeError struct {
+	Endpoint string
+	Field    string
+}
+
+// decodeLenient decodes a response of the API into v. A value which can't be
+// decoded is skipped and its field returned, instead of failing the whole
+// response.
+func decodeLenient(data []byte, v interface{}) ([]string, error) {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil, nil
+	}
+	var document interface{}
+	if json.Unmarshal(data, &document) != nil {
+		// Not a JSON document: nothing can be skipped.
+		return nil, err
+	}
+	var fields []string
+	document = sanitize(document, reflect.TypeOf(v), nil, &fields)
+	if len(fields) == 0 {
+		return nil, err
+	}
+	if data, err = json.Marshal(document); err != nil {
+		return fields, err
+	}
+	return fields, json.Unmarshal(data, v)
+}
+
+// sanitize walks the document along the type it is decoded to, and replaces
+// by null the values which can't be decoded. Their fields are appended to
+// skipped, without the array indexes.
+func sanitize(value interface{}, t reflect.Type, path []string, skipped *[]string) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if value == nil || t.Kind() == reflect.Interface || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return skipValue(value, t, path, skipped)
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return skipValue(value, t, path, skipped)
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := jsonName(field)
+			if name == "" {
+				continue
+			}
+			for key, item := range object {
+				if strings.EqualFold(key, name) {
+					object[key] = sanitize(item, field.Type, append(path, name), skipped)
+				}
+			}
+		}
+		return object
+	case reflect.Slice, reflect.Array:
+		array, ok := value.([]interface{})
+		if !ok {
+			return skipValue(value, t, path, skipped)
+		}
+		for i, item := range array {
+			array[i] = sanitize(item, t.Elem(), path, skipped)
+		}
+		return array
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return skipValue(value, t, path, skipped)
+		}
+		for key, item := range object {
+			object[key] = sanitize(item, t.Elem(), path, skipped)
+		}
+		return object
+	default:
+		return skipValue(value, t, path, skipped)
+	}
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// skipValue returns null if the value can't be decoded as the given type.
+func skipValue(value interface{}, t reflect.Type, path []string, skipped *[]string) interface{} {
+	data, err := json.Marshal(value)
+	if err == nil && json.Unmarshal(data, reflect.New(t).Interface()) == nil {
+		return value
+	}
+	*skipped = append(*skipped, strings.Join(path, "."))
+	return nil
+}
+
+// jsonName returns the name of a struct field in the JSON documents, or an
+// empty string if it is not decoded.
+func jsonName(field reflect.StructField) string {
+	if field.PkgPath != "" && !field.Anonymous {
+		return ""
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+func (client *Client) recordDecodeError(endpoint string, field string) {
+	client.decodeMu.Lock()
+	defer client.decodeMu.Unlock()
+	client.decodeErrors[DecodeError{Endpoint: endpoint, Field: field}]++
+}
+
+// DecodeErrors returns the number of values skipped per field of the
+// responses, since the client was created.
+func (client *Client) DecodeErrors() map[DecodeError]float64 {
+	client.decodeMu.Lock()
+	defer client.decodeMu.Unlock()
+	decodeErrors := make(map[DecodeError]float64, len(client.decodeErrors))
+	for decodeError, count := range client.decodeErrors {
+		decodeErrors[decodeError] = count
+	}
+	return decodeErrors
+}
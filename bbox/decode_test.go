@@ -0,0 +1,143 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFlexTypes(t *testing.T) {
+	tests := []struct {
+		value  string
+		int    flexInt
+		float  flexFloat
+		bool   flexBool
+		string flexString
+	}{
+		{`42`, 42, 42, true, "42"},
+		{`"42"`, 42, 42, true, "42"},
+		{`12.5`, 12, 12.5, true, "12.5"},
+		{`""`, 0, 0, false, ""},
+		{`null`, 0, 0, false, ""},
+		{`0`, 0, 0, false, "0"},
+		{`true`, 1, 1, true, "true"},
+		{`"off"`, 0, 0, false, "off"},
+	}
+	for _, test := range tests {
+		var v struct {
+			Int    flexInt    `json:"int"`
+			Float  flexFloat  `json:"float"`
+			Bool   flexBool   `json:"bool"`
+			String flexString `json:"string"`
+		}
+		document := `{"int":` + test.value + `,"float":` + test.value + `,"bool":` + test.value + `,"string":` + test.value + `}`
+		if test.value == `"off"` {
+			// Not a number: only decoded as a bool or a string.
+			document = `{"bool":` + test.value + `,"string":` + test.value + `}`
+		}
+		if err := json.Unmarshal([]byte(document), &v); err != nil {
+			t.Errorf("decode %s: %s", test.value, err)
+			continue
+		}
+		if v.Int != test.int || v.Float != test.float || v.Bool != test.bool || v.String != test.string {
+			t.Errorf("decode %s = %v, %v, %v, %q, want %v, %v, %v, %q",
+				test.value, v.Int, v.Float, v.Bool, v.String, test.int, test.float, test.bool, test.string)
+		}
+	}
+}
+
+type decodeTestHost struct {
+	Hostname string   `json:"hostname"`
+	Active   flexBool `json:"active"`
+	Lease    flexInt  `json:"lease"`
+}
+
+type decodeTestDocument struct {
+	Lan struct {
+		Hosts []decodeTestHost `json:"hosts"`
+		Mode  int              `json:"mode"`
+	} `json:"lan"`
+}
+
+func TestDecodeLenient(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		fields   []string
+		want     decodeTestDocument
+		err      bool
+	}{
+		{
+			name:     "valid",
+			document: `{"lan":{"hosts":[{"hostname":"tv","active":1,"lease":"3600"}],"mode":2}}`,
+			want: decodeTestDocument{Lan: struct {
+				Hosts []decodeTestHost `json:"hosts"`
+				Mode  int              `json:"mode"`
+			}{Hosts: []decodeTestHost{{Hostname: "tv", Active: true, Lease: 3600}}, Mode: 2}},
+		},
+		{
+			name:     "invalid values are skipped",
+			document: `{"lan":{"hosts":[{"hostname":"tv","active":1,"lease":"forever"},{"hostname":["nas"],"lease":60}],"mode":"bridge"}}`,
+			fields:   []string{"lan.hosts.lease", "lan.hosts.hostname", "lan.mode"},
+			want: decodeTestDocument{Lan: struct {
+				Hosts []decodeTestHost `json:"hosts"`
+				Mode  int              `json:"mode"`
+			}{Hosts: []decodeTestHost{{Hostname: "tv", Active: true}, {Lease: 60}}}},
+		},
+		{
+			name:     "array instead of an object",
+			document: `{"lan":[]}`,
+			fields:   []string{"lan"},
+		},
+		{
+			name:     "not JSON",
+			document: `<html>`,
+			err:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got decodeTestDocument
+			fields, err := decodeLenient([]byte(test.document), &got)
+			if (err != nil) != test.err {
+				t.Fatalf("decodeLenient() error = %v, want error %v", err, test.err)
+			}
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("skipped fields = %q, want %q", fields, test.fields)
+			}
+			if !test.err && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeLenient() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(`{"HOSTNAME":"tv","active":{"on":true},"lease":"1h","unknown":[1]}`), &document); err != nil {
+		t.Fatal(err)
+	}
+	var skipped []string
+	got := sanitize(document, reflect.TypeOf(decodeTestHost{}), []string{"host"}, &skipped)
+	want := map[string]interface{}{"HOSTNAME": "tv", "active": nil, "lease": nil, "unknown": []interface{}{1.0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sanitize() = %v, want %v", got, want)
+	}
+	if want := []string{"host.active", "host.lease"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped = %q, want %q", skipped, want)
+	}
+}
@@ -14,41 +14,72 @@
 
 package bbox
 
-import "github.com/go-kit/kit/log/level"
+import (
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
 
 type DeviceMetrics struct {
 	Informations []DeviceInformations `json:"informations"`
 	Memory       []DeviceMemory       `json:"device"`
 	CPU          []DeviceCPU          `json:"cpu"`
+	LED          []DeviceLED          `json:"led"`
 }
 
 type DeviceInformations struct {
 	Device struct {
-		Now           string  `json:"now"`
-		Status        float64 `json:"status"`
-		NumberOfBoots float64 `json:"numberofboots"`
-		Uptime        int     `json:"uptime"`
-		ModelName     string  `json:"modelname"`
-		Temperature   struct {
-			Current float64 `json:"current"`
-			Status  string  `json:"status"`
+		Now            string    `json:"now"`
+		Status         flexFloat `json:"status"`
+		NumberOfBoots  flexFloat `json:"numberofboots"`
+		Uptime         flexInt   `json:"uptime"`
+		ModelName      string    `json:"modelname"`
+		SerialNumber   string    `json:"serialnumber"`
+		UserConfigured flexInt   `json:"user_configured"`
+		FirstUseDate   string    `json:"firstusedate"`
+		Display        struct {
+			Luminosity flexInt `json:"luminosity"`
+			State      string  `json:"state"`
+		} `json:"display"`
+		// Main is the firmware installed, Running the one booted, and Reco
+		// the backup firmware booted when the main one fails.
+		Main        Firmware `json:"main"`
+		Reco        Firmware `json:"reco"`
+		Running     Firmware `json:"running"`
+		Bcck        Firmware `json:"bcck"`
+		Ldr1        Firmware `json:"ldr1"`
+		Ldr2        Firmware `json:"ldr2"`
+		Temperature struct {
+			Current flexFloat `json:"current"`
+			Status  string    `json:"status"`
 		} `json:"temperature"`
 		Using struct {
-			IPv4 int `json:"ipv4"`
-			IPv6 int `json:"ipv6"`
-			FTTH int `json:"ftth"`
-			ADSL int `json:"adsl"`
-			VDSL int `json:"vdsl"`
+			IPv4 flexInt `json:"ipv4"`
+			IPv6 flexInt `json:"ipv6"`
+			FTTH flexInt `json:"ftth"`
+			ADSL flexInt `json:"adsl"`
+			VDSL flexInt `json:"vdsl"`
 		} `json:"using"`
 	} `json:"device"`
 }
 
+// Firmware is the version of a firmware or of a bootloader of the Bbox
+type Firmware struct {
+	Version string `json:"version"`
+	Date    string `json:"date"`
+}
+
+// DeviceLED represents the state of the LEDs on the front of the Bbox
+type DeviceLED struct {
+	LED map[string]flexInt `json:"led"`
+}
+
 type DeviceMemory struct {
 	Device struct {
 		Memory struct {
-			Total  float64 `json:"total"`
-			Free   float64 `json:"free"`
-			Cached float64 `json:"cached"`
+			Total  flexFloat `json:"total"`
+			Free   flexFloat `json:"free"`
+			Cached flexFloat `json:"cached"`
 		} `json:"mem"`
 	} `json:"device"`
 }
@@ -57,23 +88,28 @@ type DeviceCPU struct {
 	Device struct {
 		CPU struct {
 			Time struct {
-				Total  int `json:"total"`
-				User   int `json:"user"`
-				Nice   int `json:"nice"`
-				System int `json:"system"`
-				IO     int `json:"io"`
-				Idle   int `json:"idle"`
-				Irq    int `json:"irq"`
+				Total  flexInt `json:"total"`
+				User   flexInt `json:"user"`
+				Nice   flexInt `json:"nice"`
+				System flexInt `json:"system"`
+				IO     flexInt `json:"io"`
+				Idle   flexInt `json:"idle"`
+				Irq    flexInt `json:"irq"`
 			} `json:"time"`
 			Process struct {
-				Created int `json:"created"`
-				Running int `json:"running"`
-				Blocked int `json:"blocked"`
+				Created flexInt `json:"created"`
+				Running flexInt `json:"running"`
+				Blocked flexInt `json:"blocked"`
 			} `json:"process"`
 		} `json:"cpu"`
 	} `json:"device"`
 }
 
+// Time returns the current time of the Bbox, in its time zone.
+func (informations DeviceInformations) Time() (time.Time, error) {
+	return time.Parse(timeLayout, informations.Device.Now)
+}
+
 func (client *Client) getDeviceMetrics() (*DeviceMetrics, error) {
 	var deviceStats DeviceMetrics
 
@@ -95,6 +131,13 @@ func (client *Client) getDeviceMetrics() (*DeviceMetrics, error) {
 	}
 	deviceStats.Memory = memory
 
+	led, err := client.getDeviceLED()
+	if err != nil {
+		// Optional: not every firmware provides it.
+		level.Warn(client.logger).Log("msg", "Device LED not available", "err", err)
+	}
+	deviceStats.LED = led
+
 	return &deviceStats, nil
 }
 
@@ -130,3 +173,28 @@ func (client *Client) getDeviceMemory() ([]DeviceMemory, error) {
 	}
 	return memory, nil
 }
+
+// getDeviceLED returns the state of the Bbox LEDs
+// See: https://api.bbox.fr/doc/apirouter/#api-Device-GetLed
+func (client *Client) getDeviceLED() ([]DeviceLED, error) {
+	level.Info(client.logger).Log("msg", "Retrieve device LED")
+	var led []DeviceLED
+	if err := client.apiRequest("/device/led", &led); err != nil {
+		return nil, err
+	}
+	return led, nil
+}
+
+// Reboot restarts the Bbox.
+// See: https://api.bbox.fr/doc/apirouter/#api-Device-Reboot
+func (client *Client) Reboot() error {
+	level.Warn(client.logger).Log("msg", "Reboot the Bbox")
+	return client.apiWriteRequest("POST", "/device/reboot", nil)
+}
+
+// CheckWriteAccess checks that the session can send write requests, without
+// changing anything on the Bbox.
+func (client *Client) CheckWriteAccess() error {
+	_, err := client.getToken()
+	return err
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+var tokenResponse = okResponse(`[{"device":{"token":"test"}}]`)
+
+func TestReboot(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         fixtureTransport
+		reboot        fixtureTransport
+		wantErr       bool
+		wantSupported bool
+		wantReboots   int
+	}{
+		{
+			name:          "rebooted",
+			token:         tokenResponse,
+			reboot:        okResponse(`{}`),
+			wantSupported: true,
+			wantReboots:   1,
+		},
+		{
+			name:        "not supported",
+			token:       tokenResponse,
+			reboot:      notFound,
+			wantErr:     true,
+			wantReboots: 1,
+		},
+		{
+			name:          "refused",
+			token:         tokenResponse,
+			reboot:        fixtureTransport{status: http.StatusUnauthorized, body: `{"exception":{"domain":"device","code":"401"}}`},
+			wantErr:       true,
+			wantSupported: true,
+			wantReboots:   1,
+		},
+		{
+			name:          "without token",
+			token:         okResponse(`[]`),
+			reboot:        okResponse(`{}`),
+			wantErr:       true,
+			wantSupported: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, transport := newRouteClient(t, map[string]fixtureTransport{
+				"/device/token":  test.token,
+				"/device/reboot": test.reboot,
+			})
+			err := client.Reboot()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Reboot() = %v, want error %t", err, test.wantErr)
+			}
+			if supported := !errors.Is(err, ErrNotSupported); supported != test.wantSupported {
+				t.Errorf("Reboot() = %v, want supported %t", err, test.wantSupported)
+			}
+			if n := transport.count("/device/reboot"); n != test.wantReboots {
+				t.Errorf("/device/reboot requested %d times, want %d", n, test.wantReboots)
+			}
+		})
+	}
+}
+
+func TestCheckWriteAccess(t *testing.T) {
+	client, transport := newRouteClient(t, map[string]fixtureTransport{"/device/token": tokenResponse})
+	if err := client.CheckWriteAccess(); err != nil {
+		t.Fatal(err)
+	}
+	if n := transport.count("/device/reboot"); n != 0 {
+		t.Errorf("/device/reboot requested %d times by the check, want 0", n)
+	}
+
+	client, _ = newRouteClient(t, map[string]fixtureTransport{"/device/token": notFound})
+	if err := client.CheckWriteAccess(); err == nil {
+		t.Error("CheckWriteAccess succeeded without token")
+	}
+}
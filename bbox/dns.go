@@ -17,35 +17,41 @@ package bbox
 import "github.com/go-kit/kit/log/level"
 
 type DNSMetrics struct {
-	Principal []DNSAverage `json:"principal"`
+	Servers []DNSStatistics `json:"servers"`
 }
 
-type DNSAverage struct {
+// DNSStatistics represents statistics of a resolver used by the Bbox.
+// The failures and cache fields are provided by some firmwares only.
+type DNSStatistics struct {
 	DNS struct {
-		NumberOfQueries float64 `json:"nbqueries"`
-		Min             float64 `json:"min"`
-		Max             float64 `json:"max"`
-		Average         float64 `json:"avg"`
+		Server          string    `json:"server"`
+		NumberOfQueries flexFloat `json:"nbqueries"`
+		Min             flexFloat `json:"min"`
+		Max             flexFloat `json:"max"`
+		Average         flexFloat `json:"avg"`
+		Failures        *flexInt  `json:"nbfailures"`
+		CacheHits       *flexInt  `json:"cachehits"`
+		CacheMisses     *flexInt  `json:"cachemisses"`
 	} `json:"dns"`
 }
 
 func (client *Client) getDNSMetrics() (*DNSMetrics, error) {
 	var metrics DNSMetrics
 
-	dns, err := client.getDNSAverage()
+	dns, err := client.getDNSStatistics()
 	if err != nil {
 		return nil, err
 	}
-	metrics.Principal = dns
+	metrics.Servers = dns
 
 	return &metrics, nil
 }
 
-// getDNSAverage returns information about dns average.
+// getDNSStatistics returns statistics of the resolvers.
 // See: https://api.bbox.fr/doc/apirouter/#api-DNS-GetDNS
-func (client *Client) getDNSAverage() ([]DNSAverage, error) {
+func (client *Client) getDNSStatistics() ([]DNSStatistics, error) {
 	level.Info(client.logger).Log("msg", "Retrieve DNS informations")
-	var dns []DNSAverage
+	var dns []DNSStatistics
 	if err := client.apiRequest("/dns/stats", &dns); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,62 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+type DynDNSMetrics struct {
+	Informations []DynDNSInformations `json:"informations"`
+}
+
+// DynDNSInformations represents the dynamic DNS providers configured on the Bbox
+type DynDNSInformations struct {
+	Dyndns struct {
+		Enable flexBool       `json:"enable"`
+		Domain []DynDNSDomain `json:"domain"`
+	} `json:"dyndns"`
+}
+
+type DynDNSDomain struct {
+	ID     flexInt  `json:"id"`
+	Server string   `json:"server"`
+	Host   string   `json:"host"`
+	Record string   `json:"record"`
+	Enable flexBool `json:"enable"`
+	Status struct {
+		Status string `json:"status"`
+		Date   string `json:"date"`
+		IP     string `json:"ip"`
+	} `json:"status"`
+}
+
+// LastUpdate returns the date of the last update sent to the provider.
+func (domain DynDNSDomain) LastUpdate() (time.Time, error) {
+	return time.Parse(timeLayout, domain.Status.Date)
+}
+
+// getDynDNSMetrics returns the dynamic DNS providers
+// See: https://api.bbox.fr/doc/apirouter/#api-DynDNS-GetDynDNS
+func (client *Client) getDynDNSMetrics() (*DynDNSMetrics, error) {
+	level.Info(client.logger).Log("msg", "Retrieve dynamic DNS")
+	var metrics DynDNSMetrics
+	if err := client.apiRequest("/dyndns", &metrics.Informations); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
@@ -14,6 +14,15 @@
 
 package bbox
 
+import "errors"
+
+// ErrNotSupported is returned when the firmware of the Bbox does not provide
+// a request of the API.
+var ErrNotSupported = errors.New("not supported by the Bbox API")
+
+// ErrUnknownHost is returned when a host is not known by the Bbox.
+var ErrUnknownHost = errors.New("unknown host")
+
 type APIError struct {
 	Exception struct {
 		Domain string `json:"domain"`
@@ -0,0 +1,54 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import "github.com/go-kit/kit/log/level"
+
+type HotspotMetrics struct {
+	Informations []HotspotInformations `json:"informations"`
+}
+
+// HotspotInformations represents the public Wi-Fi hotspot shared by the Bbox.
+// The clients and the traffic counters depend on the firmware.
+type HotspotInformations struct {
+	Hotspot struct {
+		Status  flexInt  `json:"status"`
+		Enable  flexBool `json:"enable"`
+		SSID    string   `json:"ssid"`
+		Clients []struct {
+			Macaddress string `json:"macaddress"`
+		} `json:"clients"`
+		Stats struct {
+			Rx HotspotTraffic `json:"rx"`
+			Tx HotspotTraffic `json:"tx"`
+		} `json:"stats"`
+	} `json:"hotspot"`
+}
+
+type HotspotTraffic struct {
+	Bytes   *flexFloat `json:"bytes"`
+	Packets *flexFloat `json:"packets"`
+}
+
+// getHotspotMetrics returns the state of the public Wi-Fi hotspot
+// See: https://api.bbox.fr/doc/apirouter/#api-Hotspot-GetHotspot
+func (client *Client) getHotspotMetrics() (*HotspotMetrics, error) {
+	level.Info(client.logger).Log("msg", "Retrieve hotspot")
+	var metrics HotspotMetrics
+	if err := client.apiRequest("/hotspot", &metrics.Informations); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
@@ -16,31 +16,128 @@ package bbox
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 )
 
+// timeLayout is the format of the dates sent by the Bbox API.
+const timeLayout = "2006-01-02T15:04:05-0700"
+
 // WTF The bbox API send result in string and/or int :(
+//
+// The flex types accept a number, a string, a boolean, an empty string or
+// null, whatever the type expected. Any other value is a decode error, which
+// does not abort the decoding of the response (see decodeLenient).
 
 // A FlexInt is an int that can be unmarshalled from a JSON field
 // that has either a number or a string value.
 // E.g. if the json field contains an string "42", the
-// FlexInt value will be "42".
+// FlexInt value will be "42". An empty string is 0.
 type flexInt int
 
 // UnmarshalJSON implements the json.Unmarshaler interface, which
 // allows us to ingest values of any json type as an int and run our custom conversion
 func (fi *flexInt) UnmarshalJSON(b []byte) error {
-	if b[0] != '"' {
-		return json.Unmarshal(b, (*int)(fi))
+	f, err := parseFlexNumber(b)
+	if err != nil {
+		return &json.UnmarshalTypeError{Value: string(b), Type: reflect.TypeOf(*fi)}
+	}
+	*fi = flexInt(f)
+	return nil
+}
+
+// A flexFloat is a float64 that can be unmarshalled from a JSON field
+// that has either a number or a string value. An empty string is 0.
+type flexFloat float64
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (ff *flexFloat) UnmarshalJSON(b []byte) error {
+	f, err := parseFlexNumber(b)
+	if err != nil {
+		return &json.UnmarshalTypeError{Value: string(b), Type: reflect.TypeOf(*ff)}
 	}
-	var s string
-	if err := json.Unmarshal(b, &s); err != nil {
+	*ff = flexFloat(f)
+	return nil
+}
+
+// A flexBool is a bool that can be unmarshalled from a boolean, a number or
+// a string. Zero, an empty string, "false", "off" and "disable" are false.
+type flexBool bool
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (fb *flexBool) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
 		return err
 	}
-	i, err := strconv.Atoi(s)
-	if err != nil {
+	switch v := value.(type) {
+	case nil:
+		*fb = false
+	case bool:
+		*fb = flexBool(v)
+	case float64:
+		*fb = v != 0
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "", "0", "false", "off", "disable", "disabled", "no":
+			*fb = false
+		default:
+			*fb = true
+		}
+	default:
+		return &json.UnmarshalTypeError{Value: string(b), Type: reflect.TypeOf(*fb)}
+	}
+	return nil
+}
+
+// A flexString is a string that can be unmarshalled from a string, a number
+// or a boolean. A number is kept as written in the response.
+type flexString string
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (fs *flexString) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
 		return err
 	}
-	*fi = flexInt(i)
+	switch v := value.(type) {
+	case nil:
+		*fs = ""
+	case string:
+		*fs = flexString(v)
+	case float64, bool:
+		*fs = flexString(strings.TrimSpace(string(b)))
+	default:
+		return &json.UnmarshalTypeError{Value: string(b), Type: reflect.TypeOf(*fs)}
+	}
 	return nil
 }
+
+// parseFlexNumber returns the number of a JSON number, string, boolean or null.
+func parseFlexNumber(b []byte) (float64, error) {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return 0, err
+	}
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(v, 64)
+	default:
+		return 0, fmt.Errorf("not a number: %s", b)
+	}
+}
@@ -31,7 +31,7 @@ type LanDevice struct {
 }
 
 type LanHost struct {
-	ID         int           `json:"id"`
+	ID         flexInt       `json:"id"`
 	Hostname   string        `json:"hostname"`
 	Macaddress string        `json:"macaddress"`
 	Ipaddress  string        `json:"ipaddress"`
@@ -39,46 +39,46 @@ type LanHost struct {
 	Link       string        `json:"link"`
 	Devicetype string        `json:"devicetype"`
 	Firstseen  string        `json:"firstseen"`
-	Lastseen   interface{}   `json:"lastseen"`
+	Lastseen   flexInt       `json:"lastseen"`
 	IP6Address []interface{} `json:"ip6address"`
 	Ethernet   struct {
-		Physicalport int         `json:"physicalport"`
-		Logicalport  int         `json:"logicalport"`
-		Speed        interface{} `json:"speed"`
-		Mode         string      `json:"mode"`
+		Physicalport flexInt `json:"physicalport"`
+		Logicalport  flexInt `json:"logicalport"`
+		Speed        flexInt `json:"speed"`
+		Mode         string  `json:"mode"`
 	} `json:"ethernet"`
 	Stb struct {
 		Product string `json:"product"`
 		Serial  string `json:"serial"`
 	} `json:"stb,omitempty"`
 	Wireless struct {
-		Band       string      `json:"band"`
-		Rssi0      interface{} `json:"rssi0"` // String or int ? "rssi0":"-76","rssi1":0,"rssi2":0
-		Rssi1      interface{} `json:"rssi1"`
-		Rssi2      interface{} `json:"rssi2"`
-		Mcs        interface{} `json:"mcs"` // Same string or int ??
-		Rate       interface{} `json:"rate"`
-		Idle       interface{} `json:"idle"`
-		Wexindex   interface{} `json:"wexindex"`
-		Starealmac interface{} `json:"starealmac"`
+		Band       string     `json:"band"`
+		Rssi0      flexInt    `json:"rssi0"` // String or int ? "rssi0":"-76","rssi1":0,"rssi2":0
+		Rssi1      flexInt    `json:"rssi1"`
+		Rssi2      flexInt    `json:"rssi2"`
+		Mcs        flexInt    `json:"mcs"` // Same string or int ??
+		Rate       flexInt    `json:"rate"`
+		Idle       flexInt    `json:"idle"`
+		Wexindex   flexInt    `json:"wexindex"`
+		Starealmac flexString `json:"starealmac"`
 	} `json:"wireless"`
 	Plc struct {
-		Rxphyrate        string `json:"rxphyrate"`
-		Txphyrate        string `json:"txphyrate"`
-		Associateddevice int    `json:"associateddevice"`
-		Interface        int    `json:"interface"`
-		Ethernetspeed    int    `json:"ethernetspeed"`
+		Rxphyrate        string  `json:"rxphyrate"`
+		Txphyrate        string  `json:"txphyrate"`
+		Associateddevice flexInt `json:"associateddevice"`
+		Interface        flexInt `json:"interface"`
+		Ethernetspeed    flexInt `json:"ethernetspeed"`
 	} `json:"plc"`
-	Lease           flexInt `json:"lease"`
-	Active          int     `json:"active"`
+	Lease           flexInt  `json:"lease"`
+	Active          flexBool `json:"active"`
 	Parentalcontrol struct {
-		Enable          int    `json:"enable"`
-		Status          string `json:"status"`
-		StatusRemaining int    `json:"statusRemaining"`
-		StatusUntil     string `json:"statusUntil"`
+		Enable          flexBool `json:"enable"`
+		Status          string   `json:"status"`
+		StatusRemaining flexInt  `json:"statusRemaining"`
+		StatusUntil     string   `json:"statusUntil"`
 	} `json:"parentalcontrol"`
 	Ping struct {
-		Average int `json:"average"`
+		Average flexInt `json:"average"`
 	} `json:"ping"`
 	Scan struct {
 		Services []interface{} `json:"services"`
@@ -109,9 +109,9 @@ type LanIPInformations struct {
 	Lan struct {
 		IP struct {
 			State      string        `json:"state"`
-			Mtu        int           `json:"mtu"`
+			Mtu        flexInt       `json:"mtu"`
 			Ipaddress  string        `json:"ipaddress"`
-			IP6Enable  int           `json:"ip6enable"`
+			IP6Enable  flexBool      `json:"ip6enable"`
 			IP6State   string        `json:"ip6state"`
 			IP6Address []interface{} `json:"ip6address"`
 			IP6Prefix  []interface{} `json:"ip6prefix"`
@@ -123,11 +123,11 @@ type LanIPInformations struct {
 		} `json:"ip"`
 		Switch struct {
 			Ports []struct {
-				ID         int    `json:"id"`
-				State      string `json:"state"`
-				LinkMode   string `json:"link_mode"`
-				Blocked    int    `json:"blocked"`
-				Flickering int    `json:"flickering"`
+				ID         flexInt `json:"id"`
+				State      string  `json:"state"`
+				LinkMode   string  `json:"link_mode"`
+				Blocked    flexInt `json:"blocked"`
+				Flickering flexInt `json:"flickering"`
 			} `json:"ports"`
 		} `json:"switch"`
 	} `json:"lan"`
@@ -151,6 +151,19 @@ func (client *Client) getLanMetrics() (*LanMetrics, error) {
 	return &metrics, nil
 }
 
+// GetLanHosts returns the hosts known by the Bbox.
+func (client *Client) GetLanHosts() ([]LanHost, error) {
+	devices, err := client.getLanDevices()
+	if err != nil {
+		return nil, err
+	}
+	var hosts []LanHost
+	for _, device := range devices {
+		hosts = append(hosts, device.Hosts.List...)
+	}
+	return hosts, nil
+}
+
 // returns ip configuration of the Bbox local Network.
 // See: https://api.bbox.fr/doc/apirouter/#api-LAN-GetLanIP
 func (client *Client) getLanInformations() ([]LanIPInformations, error) {
@@ -0,0 +1,61 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import "github.com/go-kit/kit/log/level"
+
+type ParentalControlMetrics struct {
+	Informations []ParentalControlInformations `json:"informations"`
+}
+
+// ParentalControlInformations represents the parental control configuration
+type ParentalControlInformations struct {
+	ParentalControl struct {
+		Enable        flexBool              `json:"enable"`
+		DefaultPolicy string                `json:"defaultpolicy"`
+		List          []ParentalControlRule `json:"list"`
+	} `json:"parentalcontrol"`
+}
+
+// ParentalControlRule is the schedule of the internet access of a host
+type ParentalControlRule struct {
+	ID         flexInt    `json:"id"`
+	Enable     flexBool   `json:"enable"`
+	Macaddress string     `json:"macaddress"`
+	Scheduler  []Schedule `json:"scheduler"`
+}
+
+func (client *Client) getParentalControlMetrics() (*ParentalControlMetrics, error) {
+	var metrics ParentalControlMetrics
+
+	informations, err := client.getParentalControlInformations()
+	if err != nil {
+		return nil, err
+	}
+	metrics.Informations = informations
+
+	return &metrics, nil
+}
+
+// getParentalControlInformations returns the parental control configuration
+// See: https://api.bbox.fr/doc/apirouter/#api-ParentalControl-GetParentalControl
+func (client *Client) getParentalControlInformations() ([]ParentalControlInformations, error) {
+	level.Info(client.logger).Log("msg", "Retrieve parental control informations")
+	var informations []ParentalControlInformations
+	if err := client.apiRequest("/parentalcontrol", &informations); err != nil {
+		return nil, err
+	}
+	return informations, nil
+}
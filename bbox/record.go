@@ -0,0 +1,356 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The responses are stored in a directory, one file per endpoint: the path of
+// /wan/ip is stored in wan_ip.json. Write requests are prefixed with their
+// method, e.g. post_device_reboot.json.
+
+// sensitiveKeys are the fields removed from the recorded responses, whatever
+// their value.
+var sensitiveKeys = map[string]bool{
+	"serialnumber": true,
+	"serial":       true,
+	"token":        true,
+	"password":     true,
+	"username":     true,
+	"login":        true,
+	"passphrase":   true,
+	"key":          true,
+}
+
+// nameKeys are the fields naming the hosts and networks of the user, replaced
+// by a fake name of the given kind.
+var nameKeys = map[string]string{
+	"hostname":         "host",
+	"host":             "host",
+	"friendlyname":     "host",
+	"userfriendlyname": "host",
+	"ssid":             "ssid",
+}
+
+// phoneKeys are the fields of the VoIP lines and calls holding phone numbers
+// or SIP URIs.
+var phoneKeys = map[string]bool{
+	"uri":          true,
+	"number":       true,
+	"phonenumber":  true,
+	"callernumber": true,
+	"callednumber": true,
+}
+
+var macAddress = regexp.MustCompile(`^([0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}$`)
+
+// addressToken matches the items of a list of addresses, such as the DNS
+// servers "192.168.1.254,8.8.8.8".
+var addressToken = regexp.MustCompile(`[^,;\s]+`)
+
+// sipURI matches the user and the domain of a SIP or tel URI, such as
+// "sip:0123456789@ims.example.fr".
+var sipURI = regexp.MustCompile(`^((?:sips?|tel):)?([^@]+)(@.*)?$`)
+
+// phoneNumber matches a national or international phone number.
+var phoneNumber = regexp.MustCompile(`^\+?[0-9][0-9 .-]{5,}[0-9]$`)
+
+// fixtureName returns the name of the file of a request.
+func fixtureName(request *http.Request) string {
+	path := strings.Trim(strings.TrimPrefix(request.URL.Path, apiVersion), "/")
+	name := strings.Replace(path, "/", "_", -1)
+	if name == "" {
+		name = "root"
+	}
+	if request.Method != http.MethodGet {
+		name = strings.ToLower(request.Method) + "_" + name
+	}
+	return name + ".json"
+}
+
+// RecordTransport saves the responses of the Bbox API in a directory, with
+// the MAC addresses, IP addresses, names, phone numbers, serials and secrets
+// redacted. A response which can't be saved fails the request.
+type RecordTransport struct {
+	dir  string
+	next http.RoundTripper
+	// key is the secret of the keyed hash giving the fake values.
+	key      []byte
+	mu       sync.Mutex
+	redacted map[string]string
+	// used are the fake values given, to resolve the collisions.
+	used map[string]bool
+}
+
+// NewRecordTransport returns a transport recording the responses of next in
+// dir. The fake values are derived from the real ones with the given key, so
+// they don't change between two recordings with the same key. When the key
+// is empty, a random one is used.
+func NewRecordTransport(dir string, key string, next http.RoundTripper) (*RecordTransport, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	secret := []byte(key)
+	if key == "" {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return newRecordTransport(dir, secret, next), nil
+}
+
+func newRecordTransport(dir string, key []byte, next http.RoundTripper) *RecordTransport {
+	return &RecordTransport{
+		dir:      dir,
+		next:     next,
+		key:      key,
+		redacted: map[string]string{},
+		used:     map[string]bool{},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RecordTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(request)
+	if err != nil || strings.HasSuffix(request.URL.Path, "/login") {
+		return resp, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	var content interface{}
+	if err := json.Unmarshal(body, &content); err != nil {
+		// Not JSON: nothing to record.
+		return resp, nil
+	}
+	t.mu.Lock()
+	content = t.redact("", content)
+	t.mu.Unlock()
+	fixture, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("can't record %s: %s", request.URL.Path, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(t.dir, fixtureName(request)), append(fixture, '\n'), 0600); err != nil {
+		return nil, fmt.Errorf("can't record %s: %s", request.URL.Path, err)
+	}
+	return resp, nil
+}
+
+// redact replaces the sensitive values of a JSON document. A MAC address, IP
+// address, name or phone number is always replaced by the same fake value, so
+// the relations between the hosts are kept.
+func (t *RecordTransport) redact(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// In the order of the keys, so the rare collisions of fake values
+		// are resolved the same way at each recording.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if strings.ToLower(key) == "ssid" && k == "id" {
+				// The name of the network, in the settings of the radios.
+				v[k] = t.redact(key, v[k])
+				continue
+			}
+			v[k] = t.redact(k, v[k])
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = t.redact(key, item)
+		}
+		return v
+	case string:
+		if v == "" {
+			return v
+		}
+		if sensitiveKeys[strings.ToLower(key)] {
+			return "REDACTED"
+		}
+		if kind, ok := nameKeys[strings.ToLower(key)]; ok && net.ParseIP(v) == nil {
+			return t.fake(kind, v)
+		}
+		if phoneKeys[strings.ToLower(key)] {
+			return t.redactPhone(v)
+		}
+		return addressToken.ReplaceAllStringFunc(v, t.redactAddress)
+	default:
+		if sensitiveKeys[strings.ToLower(key)] {
+			return "REDACTED"
+		}
+		return v
+	}
+}
+
+// redactAddress replaces a MAC address, an IP address or an IP prefix. Other
+// values are returned unchanged.
+func (t *RecordTransport) redactAddress(value string) string {
+	if macAddress.MatchString(value) {
+		// The Bbox writes the same MAC address in upper or lower case.
+		return t.fake("mac", strings.ToLower(strings.Replace(value, "-", ":", -1)))
+	}
+	address, prefix := value, ""
+	if i := strings.Index(value, "/"); i >= 0 {
+		address, prefix = value[:i], value[i:]
+	}
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return value
+	case ip.To4() != nil:
+		return t.fake("ipv4", ip.String()) + prefix
+	default:
+		return t.fake("ipv6", ip.String()) + prefix
+	}
+}
+
+// redactPhone replaces the phone number, or the user of a SIP URI. The
+// domain of the URI is kept.
+func (t *RecordTransport) redactPhone(value string) string {
+	match := sipURI.FindStringSubmatch(value)
+	if match == nil {
+		return value
+	}
+	scheme, user, domain := match[1], match[2], match[3]
+	if scheme == "" && domain == "" && !phoneNumber.MatchString(user) {
+		// Not a phone number, e.g. the number of a TV channel.
+		return value
+	}
+	if phoneNumber.MatchString(user) {
+		// The same number may be written in the national or international
+		// format.
+		number := strings.NewReplacer(" ", "", ".", "", "-", "").Replace(user)
+		if strings.HasPrefix(number, "+33") {
+			number = "0" + number[3:]
+		}
+		return scheme + t.fake("phone", number) + domain
+	}
+	return scheme + t.fake("user", user) + domain
+}
+
+// fake returns the fake value of the given kind replacing value. It is
+// derived from a keyed hash of the value, so it doesn't depend on the other
+// values of the recording.
+func (t *RecordTransport) fake(kind string, value string) string {
+	if fake, ok := t.redacted[kind+"/"+value]; ok {
+		return fake
+	}
+	var fake string
+	for i := 0; ; i++ {
+		mac := hmac.New(sha256.New, t.key)
+		fmt.Fprintf(mac, "%s/%s/%d", kind, value, i)
+		sum := mac.Sum(nil)
+		n := binary.BigEndian.Uint32(sum)
+		switch kind {
+		case "mac":
+			// Locally administered addresses.
+			fake = fmt.Sprintf("02:%02x:%02x:%02x:%02x:%02x", sum[0], sum[1], sum[2], sum[3], sum[4])
+		case "ipv4":
+			// Benchmarking addresses, see RFC 2544: 198.18.0.0/15.
+			fake = fmt.Sprintf("198.%d.%d.%d", 18+(n>>16&1), n>>8&0xff, n&0xff)
+		case "ipv6":
+			// Documentation addresses, see RFC 3849.
+			ip := net.IP(append([]byte{0x20, 0x01, 0x0d, 0xb8}, sum[:12]...))
+			fake = ip.String()
+		case "phone":
+			// Numbers reserved for fiction by the ARCEP: 01 99 00 00 00 to
+			// 01 99 99 99 99.
+			fake = fmt.Sprintf("0199%06d", n%1000000)
+		default:
+			fake = kind + "-" + hex.EncodeToString(sum[:3])
+		}
+		// Two values with the same fake value get the next one.
+		if !t.used[fake] {
+			break
+		}
+	}
+	t.redacted[kind+"/"+value] = fake
+	t.used[fake] = true
+	return fake
+}
+
+// ReplayTransport answers the requests to the Bbox API with the responses
+// saved by a RecordTransport. A missing response is answered with 404.
+type ReplayTransport struct {
+	dir string
+}
+
+// NewReplayTransport returns a transport replaying the responses of dir.
+func NewReplayTransport(dir string) *ReplayTransport {
+	return &ReplayTransport{dir: dir}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *ReplayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Body != nil {
+		request.Body.Close()
+	}
+	resp := &http.Response{
+		Request:    request,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+	}
+	if strings.HasSuffix(request.URL.Path, "/login") {
+		resp.StatusCode = http.StatusOK
+		resp.Header.Set("Set-Cookie", "BBOX_ID=replay; Path=/")
+		resp.Body = ioutil.NopCloser(strings.NewReader(""))
+		return resp, nil
+	}
+	body, err := ioutil.ReadFile(filepath.Join(t.dir, fixtureName(request)))
+	if os.IsNotExist(err) {
+		resp.StatusCode = http.StatusNotFound
+		resp.Body = ioutil.NopCloser(strings.NewReader(`{"exception":{"domain":"replay","code":"404"}}`))
+		return resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.StatusCode = http.StatusOK
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
@@ -0,0 +1,201 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testKey is the key of the fake values of the tests.
+var testKey = []byte("test")
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{
+			name:     "secrets",
+			document: `{"serialnumber":"AN1234","password":"secret","key":42,"enable":1}`,
+			want:     `{"serialnumber":"REDACTED","password":"REDACTED","key":"REDACTED","enable":1}`,
+		},
+		{
+			name:     "same MAC address in both cases",
+			document: `[{"macaddress":"AA:BB:CC:DD:EE:FF"},{"macaddress":"aa-bb-cc-dd-ee-ff"},{"macaddress":"11:22:33:44:55:66"}]`,
+			want:     `[{"macaddress":"02:61:a6:00:f2:3b"},{"macaddress":"02:61:a6:00:f2:3b"},{"macaddress":"02:f2:d0:a5:43:d0"}]`,
+		},
+		{
+			name:     "list of DNS servers",
+			document: `{"dnsservers":"192.168.1.254,8.8.8.8","ipaddress":"8.8.8.8"}`,
+			want:     `{"dnsservers":"198.19.246.112,198.19.176.212","ipaddress":"198.19.176.212"}`,
+		},
+		{
+			name:     "IPv6 prefix",
+			document: `{"ip6address":"2a01:cb00:1234:5600:0:0:0:1","ip6prefix":"2a01:cb00:1234:5600::/56"}`,
+			want:     `{"ip6address":"2001:db8:986e:9ccc:9840:1f64:9ae3:f909","ip6prefix":"2001:db8:8343:36ee:d10b:b985:7e64:ac9a/56"}`,
+		},
+		{
+			name:     "host names",
+			document: `[{"hostname":"laptop-alice","ipaddress":"192.168.1.20"},{"hostname":"laptop-alice"},{"hostname":"tv"}]`,
+			want:     `[{"hostname":"host-7706be","ipaddress":"198.19.34.236"},{"hostname":"host-7706be"},{"hostname":"host-501d2c"}]`,
+		},
+		{
+			name:     "SSID",
+			document: `{"wireless":{"radio":{"ssid":{"id":"Maison","enable":1}},"stats":{"ssid":{"id":24}},"hotspot":{"ssid":"Maison-guest"}}}`,
+			want:     `{"wireless":{"radio":{"ssid":{"id":"ssid-9a3505","enable":1}},"stats":{"ssid":{"id":24}},"hotspot":{"ssid":"ssid-bd60d1"}}}`,
+		},
+		{
+			name:     "VoIP lines",
+			document: `[{"voip":[{"id":1,"status":"Up","uri":"0123456789@ims.bouyguestelecom.fr","login":"0123456789"},{"id":2,"uri":"sip:jdoe@sip.example.fr"}]}]`,
+			want:     `[{"voip":[{"id":1,"status":"Up","uri":"0199520200@ims.bouyguestelecom.fr","login":"REDACTED"},{"id":2,"uri":"sip:user-ddbb3d@sip.example.fr"}]}]`,
+		},
+		{
+			name:     "VoIP calls",
+			document: `[{"calllog":[{"id":1,"number":"+33 6 12 34 56 78","type":"in"},{"id":2,"number":"06.12.34.56.78"},{"id":3,"number":"3900"}]}]`,
+			want:     `[{"calllog":[{"id":1,"number":"0199574403","type":"in"},{"id":2,"number":"0199574403"},{"id":3,"number":"3900"}]}]`,
+		},
+		{
+			name:     "other values",
+			document: `{"state":"Up","version":"20.6.8","date":"2021-10-19T10:00:00+0200"}`,
+			want:     `{"state":"Up","version":"20.6.8","date":"2021-10-19T10:00:00+0200"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := newRecordTransport("", testKey, nil)
+			var document, want interface{}
+			if err := json.Unmarshal([]byte(test.document), &document); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if got := transport.redact("", document); !reflect.DeepEqual(got, want) {
+				t.Errorf("redact(%s) = %v, want %s", test.document, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRedactAddressUnique(t *testing.T) {
+	transport := newRecordTransport("", testKey, nil)
+	fakes := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		fake := transport.redactAddress(fmt.Sprintf("10.0.%d.%d", i/250, i%250+1))
+		if fakes[fake] {
+			t.Fatalf("fake address %s given twice", fake)
+		}
+		fakes[fake] = true
+	}
+}
+
+// The fake value of an address doesn't depend on the other addresses of the
+// recording, so adding a host doesn't change the fixtures of the others.
+func TestRedactStable(t *testing.T) {
+	before := newRecordTransport("", testKey, nil)
+	after := newRecordTransport("", testKey, nil)
+	after.redactAddress("192.168.1.10")
+	after.redactAddress("aa:bb:cc:dd:ee:01")
+	for _, value := range []string{"192.168.1.20", "aa:bb:cc:dd:ee:02", "2a01:cb00::1"} {
+		if got, want := after.redactAddress(value), before.redactAddress(value); got != want {
+			t.Errorf("fake value of %s = %s after another host, want %s", value, got, want)
+		}
+	}
+	other := newRecordTransport("", []byte("other"), nil)
+	if other.redactAddress("192.168.1.20") == before.redactAddress("192.168.1.20") {
+		t.Error("same fake value with another key")
+	}
+}
+
+// fixtureTransport answers the requests with a fixed JSON document.
+type fixtureTransport struct {
+	status int
+	body   string
+}
+
+func (t fixtureTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: t.status,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(t.body)),
+		Request:    request,
+	}, nil
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bbox-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	recorder, err := NewRecordTransport(dir, "", fixtureTransport{
+		status: http.StatusOK,
+		body:   `[{"device":{"serialnumber":"AN1234","modelname":"Bbox Fiber"}}]`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, _ := http.NewRequest("GET", "https://bbox.test/api/v1/device", nil)
+	resp, err := recorder.RoundTrip(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, err := os.Stat(filepath.Join(dir, "device.json")); err != nil {
+		t.Fatalf("response not recorded: %s", err)
+	}
+
+	replay := NewReplayTransport(dir)
+	resp, err = replay.RoundTrip(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var device []DeviceInformations
+	if err := json.NewDecoder(resp.Body).Decode(&device); err != nil {
+		t.Fatal(err)
+	}
+	if len(device) != 1 || device[0].Device.ModelName != "Bbox Fiber" {
+		t.Errorf("replayed device = %+v", device)
+	}
+
+	request, _ = http.NewRequest("GET", "https://bbox.test/api/v1/wan/ip", nil)
+	resp, err = replay.RoundTrip(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status of a missing response = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestRecordWriteError(t *testing.T) {
+	recorder := newRecordTransport(filepath.Join(os.DevNull, "missing"), testKey, fixtureTransport{status: http.StatusOK, body: `{"state":"Up"}`})
+	request, _ := http.NewRequest("GET", "https://bbox.test/api/v1/wan/ip", nil)
+	if _, err := recorder.RoundTrip(request); err == nil {
+		t.Error("RoundTrip succeeded, want a write error")
+	}
+}
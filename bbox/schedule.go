@@ -0,0 +1,57 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"strings"
+	"time"
+)
+
+// Schedule is a weekly time slot of the parental control or Wi-Fi scheduler
+type Schedule struct {
+	Start ScheduleTime `json:"start"`
+	End   ScheduleTime `json:"end"`
+}
+
+// ScheduleTime is a day of the week with a time of the day
+type ScheduleTime struct {
+	Day    string  `json:"day"`
+	Hour   flexInt `json:"hour"`
+	Minute flexInt `json:"minute"`
+}
+
+// minutes returns the minutes since the start of the week, or -1 for an unknown day.
+func (t ScheduleTime) minutes() int {
+	for i := time.Sunday; i <= time.Saturday; i++ {
+		if strings.EqualFold(t.Day, i.String()) {
+			return (int(i)*24+int(t.Hour))*60 + int(t.Minute)
+		}
+	}
+	return -1
+}
+
+// Contains returns true if the time is in the slot. Slots may wrap over the
+// end of the week.
+func (s Schedule) Contains(t time.Time) bool {
+	start, end := s.Start.minutes(), s.End.minutes()
+	if start < 0 || end < 0 {
+		return false
+	}
+	now := (int(t.Weekday())*24+t.Hour())*60 + t.Minute()
+	if start <= end {
+		return start <= now && now < end
+	}
+	return now >= start || now < end
+}
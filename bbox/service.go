@@ -24,80 +24,80 @@ type ServicesInformations struct {
 	Services struct {
 		Now      string `json:"now"`
 		Firewall struct {
-			Status  int `json:"status"`
-			Enable  int `json:"enable"`
-			Nbrules int `json:"nbrules"`
+			Status  flexInt  `json:"status"`
+			Enable  flexBool `json:"enable"`
+			Nbrules flexInt  `json:"nbrules"`
 		} `json:"firewall"`
 		Dyndns struct {
-			State   int `json:"state"`
-			Enable  int `json:"enable"`
-			Nbrules int `json:"nbrules"`
+			State   flexInt  `json:"state"`
+			Enable  flexBool `json:"enable"`
+			Nbrules flexInt  `json:"nbrules"`
 		} `json:"dyndns"`
 		Dhcp struct {
-			Status  int `json:"status"`
-			Enable  int `json:"enable"`
-			Nbrules int `json:"nbrules"`
+			Status  flexInt  `json:"status"`
+			Enable  flexBool `json:"enable"`
+			Nbrules flexInt  `json:"nbrules"`
 		} `json:"dhcp"`
 		Nat struct {
-			Status  int `json:"status"`
-			Enable  int `json:"enable"`
-			Nbrules int `json:"nbrules"`
+			Status  flexInt  `json:"status"`
+			Enable  flexBool `json:"enable"`
+			Nbrules flexInt  `json:"nbrules"`
 		} `json:"nat"`
 		Gamermode struct {
-			Status int `json:"status"`
-			Enable int `json:"enable"`
+			Status flexInt  `json:"status"`
+			Enable flexBool `json:"enable"`
 		} `json:"gamermode"`
 		Upnp struct {
 			Igd struct {
-				Status  int `json:"status"`
-				Enable  int `json:"enable"`
-				Nbrules int `json:"nbrules"`
+				Status  flexInt  `json:"status"`
+				Enable  flexBool `json:"enable"`
+				Nbrules flexInt  `json:"nbrules"`
 			} `json:"igd"`
 		} `json:"upnp"`
 		Remote struct {
 			Proxywol struct {
-				Status int    `json:"status,string"`
-				Enable int    `json:"enable"`
-				IP     string `json:"ip"`
+				Status flexInt  `json:"status"`
+				Enable flexBool `json:"enable"`
+				IP     string   `json:"ip"`
 			} `json:"proxywol"`
 			Admin struct {
-				Status     int    `json:"status"`
-				Enable     int    `json:"enable"`
-				Port       int    `json:"port"`
-				IP         string `json:"ip"`
-				Duration   string `json:"duration"`
-				Activable  int    `json:"activable"`
-				IP6Address string `json:"ip6address"`
+				Status     flexInt  `json:"status"`
+				Enable     flexBool `json:"enable"`
+				Port       flexInt  `json:"port"`
+				IP         string   `json:"ip"`
+				Duration   string   `json:"duration"`
+				Activable  flexInt  `json:"activable"`
+				IP6Address string   `json:"ip6address"`
 			} `json:"admin"`
 		} `json:"remote"`
 		Parentalcontrol struct {
-			Enable int `json:"enable"`
+			Enable flexBool `json:"enable"`
 		} `json:"parentalcontrol"`
 		Wifischeduler struct {
-			Enable int `json:"enable"`
+			Enable flexBool `json:"enable"`
 		} `json:"wifischeduler"`
 		Voipscheduler struct {
-			Enable int `json:"enable"`
+			Enable flexBool `json:"enable"`
 		} `json:"voipscheduler"`
 		Notification struct {
-			Enable int `json:"enable"`
+			Enable flexBool `json:"enable"`
 		} `json:"notification"`
 		Hotspot struct {
-			Status int `json:"status"`
-			Enable int `json:"enable"`
+			Status flexInt  `json:"status"`
+			Enable flexBool `json:"enable"`
 		} `json:"hotspot"`
 		Usb struct {
 			Samba struct {
-				Status int `json:"status"`
-				Enable int `json:"enable"`
+				Status flexInt  `json:"status"`
+				Enable flexBool `json:"enable"`
 			} `json:"samba"`
 			Printer struct {
-				Status int `json:"status"`
-				Enable int `json:"enable"`
+				Status flexInt  `json:"status"`
+				Enable flexBool `json:"enable"`
 			} `json:"printer"`
 			Dlna struct {
-				Status int `json:"status"`
-				Enable int `json:"enable"`
+				Status flexInt  `json:"status"`
+				Enable flexBool `json:"enable"`
 			} `json:"dlna"`
 		} `json:"usb"`
 	} `json:"services"`
@@ -0,0 +1,95 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"fmt"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// SummaryInformations represents the overview of the state of the Bbox, as
+// displayed by its web interface.
+type SummaryInformations struct {
+	Internet struct {
+		// 2 when the Bbox is connected to the Internet
+		State flexInt `json:"state"`
+	} `json:"internet"`
+	Display struct {
+		Luminosity flexInt `json:"luminosity"`
+	} `json:"display"`
+	Wan struct {
+		IP struct {
+			Address string `json:"address"`
+			State   string `json:"state"`
+		} `json:"ip"`
+		// Only the line of the Bbox is listed.
+		XDsl *struct {
+			State string `json:"state"`
+		} `json:"xdsl"`
+		Ftth *struct {
+			State string `json:"state"`
+		} `json:"ftth"`
+	} `json:"wan"`
+	Wireless struct {
+		Status flexInt `json:"status"`
+		Radio  map[string]struct {
+			Enable   flexBool `json:"enable"`
+			State    flexInt  `json:"state"`
+			Standard string   `json:"standard"`
+		} `json:"radio"`
+	} `json:"wireless"`
+	Hosts []struct {
+		Hostname  string   `json:"hostname"`
+		Ipaddress string   `json:"ipaddress"`
+		Link      string   `json:"link"`
+		Active    flexBool `json:"active"`
+	} `json:"hosts"`
+	Voip []struct {
+		ID          flexInt `json:"id"`
+		Status      string  `json:"status"`
+		Callstate   string  `json:"callstate"`
+		Message     flexInt `json:"message"`
+		Notanswered flexInt `json:"notanswered"`
+	} `json:"voip"`
+	Iptv *[]struct {
+		Address   string  `json:"address"`
+		Ipaddress string  `json:"ipaddress"`
+		Number    flexInt `json:"number"`
+		Receipt   flexInt `json:"receipt"`
+	} `json:"iptv"`
+	Usb *struct {
+		Printer []struct {
+			Product string `json:"product"`
+		} `json:"printer"`
+		Storage []struct {
+			Label string `json:"label"`
+		} `json:"storage"`
+	} `json:"usb"`
+}
+
+// GetSummary returns the overview of the state of the Bbox, in one request.
+// See: https://api.bbox.fr/doc/apirouter/#api-Summary-GetSummary
+func (client *Client) GetSummary() (*SummaryInformations, error) {
+	level.Info(client.logger).Log("msg", "Retrieve summary")
+	var summary []SummaryInformations
+	if err := client.apiRequest("/summary", &summary); err != nil {
+		return nil, err
+	}
+	if len(summary) == 0 {
+		return nil, fmt.Errorf("empty summary")
+	}
+	return &summary[0], nil
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import "github.com/go-kit/kit/log/level"
+
+type USBMetrics struct {
+	Devices  []USBDevices  `json:"devices"`
+	Storage  []USBStorage  `json:"storage"`
+	Printers []USBPrinters `json:"printers"`
+}
+
+// USBDevices represents the devices plugged on the USB ports of the Bbox
+type USBDevices struct {
+	USB struct {
+		Devices []struct {
+			ID           flexInt `json:"id"`
+			Type         string  `json:"type"`
+			Manufacturer string  `json:"manufacturer"`
+			Product      string  `json:"product"`
+			State        string  `json:"state"`
+		} `json:"devices"`
+	} `json:"usb"`
+}
+
+// USBStorage represents the partitions of the USB storage devices
+type USBStorage struct {
+	Storage struct {
+		Partitions []USBPartition `json:"partitions"`
+	} `json:"storage"`
+}
+
+type USBPartition struct {
+	ID     flexInt   `json:"id"`
+	Device flexInt   `json:"device"`
+	Label  string    `json:"label"`
+	FSType string    `json:"fstype"`
+	State  string    `json:"state"`
+	Total  flexFloat `json:"total"` // bytes
+	Used   flexFloat `json:"used"`  // bytes
+}
+
+// USBPrinters represents the printers shared by the Bbox
+type USBPrinters struct {
+	Printer []struct {
+		ID      flexInt `json:"id"`
+		Product string  `json:"product"`
+		State   string  `json:"state"`
+	} `json:"printer"`
+}
+
+// getUSBMetrics returns the USB devices. The storage and printer requests
+// are optional, as they fail when no such device is plugged.
+func (client *Client) getUSBMetrics() (*USBMetrics, error) {
+	var metrics USBMetrics
+
+	devices, err := client.getUSBDevices()
+	if err != nil {
+		return nil, err
+	}
+	metrics.Devices = devices
+
+	storage, err := client.getUSBStorage()
+	if err != nil {
+		level.Warn(client.logger).Log("msg", "USB storage not available", "err", err)
+	}
+	metrics.Storage = storage
+
+	printers, err := client.getUSBPrinters()
+	if err != nil {
+		level.Warn(client.logger).Log("msg", "USB printers not available", "err", err)
+	}
+	metrics.Printers = printers
+
+	return &metrics, nil
+}
+
+// getUSBDevices returns the USB devices plugged on the Bbox
+// See: https://api.bbox.fr/doc/apirouter/#api-USB-GetUSB
+func (client *Client) getUSBDevices() ([]USBDevices, error) {
+	level.Info(client.logger).Log("msg", "Retrieve USB devices")
+	var devices []USBDevices
+	if err := client.apiRequest("/usb", &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// getUSBStorage returns the partitions of the USB storage devices
+// See: https://api.bbox.fr/doc/apirouter/#api-USB-GetUSBStorage
+func (client *Client) getUSBStorage() ([]USBStorage, error) {
+	level.Info(client.logger).Log("msg", "Retrieve USB storage")
+	var storage []USBStorage
+	if err := client.apiRequest("/usb/storage", &storage); err != nil {
+		return nil, err
+	}
+	return storage, nil
+}
+
+// getUSBPrinters returns the USB printers
+// See: https://api.bbox.fr/doc/apirouter/#api-USB-GetUSBPrinter
+func (client *Client) getUSBPrinters() ([]USBPrinters, error) {
+	level.Info(client.logger).Log("msg", "Retrieve USB printers")
+	var printers []USBPrinters
+	if err := client.apiRequest("/usb/printer", &printers); err != nil {
+		return nil, err
+	}
+	return printers, nil
+}
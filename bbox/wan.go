@@ -15,13 +15,20 @@
 package bbox
 
 import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
 	"github.com/go-kit/kit/log/level"
 )
 
 type WanMetrics struct {
 	IPInformations        []WanIPInformations  `json:"ip_informations"`
 	IPStatistics          []WanIPStatistics    `json:"ip_statistics"`
-	FtthStatistics        *FtthStatistics      `json:"ftth_statistics"`
+	FtthInformations      []FtthInformations   `json:"ftth_informations"`
+	FtthStatistics        []FtthStatistics     `json:"ftth_statistics"`
 	DiagnosticsStatistics []WanDiagsStatistics `json:"diagnostics"`
 	XDslStatistics        []WanXDslStat        `json:"xdsl_statistics"`
 	XDslInformations      []WanXDslInfo        `json:"xdsl_informations"`
@@ -32,33 +39,30 @@ type WanIPStatistics struct {
 		IP struct {
 			Stats struct {
 				Rx struct {
-					Packets         flexInt `json:"packets"`
-					Bytes           flexInt `json:"bytes"` // See: https://github.com/nlamirault/bbox_exporter/issues/1
-					Packetserrors   flexInt `json:"packetserrors"`
-					Packetsdiscards flexInt `json:"packetsdiscards"`
-					Occupation      float64 `json:"occupation"`
-					Bandwidth       flexInt `json:"bandwidth"`
-					MaxBandwidth    flexInt `json:"maxBandwidth"`
+					Packets         flexInt   `json:"packets"`
+					Bytes           flexInt   `json:"bytes"` // See: https://github.com/nlamirault/bbox_exporter/issues/1
+					Packetserrors   flexInt   `json:"packetserrors"`
+					Packetsdiscards flexInt   `json:"packetsdiscards"`
+					Occupation      flexFloat `json:"occupation"`
+					Bandwidth       flexInt   `json:"bandwidth"`
+					MaxBandwidth    flexInt   `json:"maxBandwidth"`
 				} `json:"rx"`
 				Tx struct {
-					Packets         flexInt `json:"packets"`
-					Bytes           flexInt `json:"bytes"` // See: https://github.com/nlamirault/bbox_exporter/issues/1
-					Packetserrors   flexInt `json:"packetserrors"`
-					Packetsdiscards flexInt `json:"packetsdiscards"`
-					Occupation      float64 `json:"occupation"`
-					Bandwidth       flexInt `json:"bandwidth"`
-					MaxBandwidth    flexInt `json:"maxBandwidth"`
+					Packets         flexInt   `json:"packets"`
+					Bytes           flexInt   `json:"bytes"` // See: https://github.com/nlamirault/bbox_exporter/issues/1
+					Packetserrors   flexInt   `json:"packetserrors"`
+					Packetsdiscards flexInt   `json:"packetsdiscards"`
+					Occupation      flexFloat `json:"occupation"`
+					Bandwidth       flexInt   `json:"bandwidth"`
+					MaxBandwidth    flexInt   `json:"maxBandwidth"`
 				} `json:"tx"`
 			} `json:"stats"`
 		} `json:"ip"`
 	} `json:"wan"`
 }
 
-type FtthStatistics []struct {
-	Ftth Ftth `json:"ftth"`
-}
-
-type Ftth struct {
+// FtthInformations represents the state of the FTTH link
+type FtthInformations struct {
 	Wan struct {
 		Ftth struct {
 			Mode  string `json:"mode"`
@@ -67,16 +71,32 @@ type Ftth struct {
 	} `json:"wan"`
 }
 
+// FtthStatistics represents the diagnostics of the optical module.
+// Not every firmware provides them.
+type FtthStatistics struct {
+	Wan struct {
+		Ftth struct {
+			Stats struct {
+				Temperature flexFloat `json:"temperature"` // °C
+				Voltage     flexFloat `json:"vcc"`         // V
+				Bias        flexFloat `json:"txbias"`      // mA
+				TxPower     flexFloat `json:"txpower"`     // dBm
+				RxPower     flexFloat `json:"rxpower"`     // dBm
+			} `json:"stats"`
+		} `json:"ftth"`
+	} `json:"wan"`
+}
+
 type WanXDslStat struct {
 	Wan struct {
 		XDsl struct {
 			Stats struct {
-				LocalFEC  int `json:"local_fec"`
-				RemoteFEC int `json:"remote_fec"`
-				LocalCRC  int `json:"local_crc"`
-				RemoteCRC int `json:"remote_crc"`
-				LocalHEC  int `json:"local_hec"`
-				RemoteHEC int `json:"remote_hec"`
+				LocalFEC  flexInt `json:"local_fec"`
+				RemoteFEC flexInt `json:"remote_fec"`
+				LocalCRC  flexInt `json:"local_crc"`
+				RemoteCRC flexInt `json:"remote_crc"`
+				LocalHEC  flexInt `json:"local_hec"`
+				RemoteHEC flexInt `json:"remote_hec"`
 			} `json:"stats"`
 		} `json:"xdsl"`
 	} `json:"wan"`
@@ -85,45 +105,40 @@ type WanXDslStat struct {
 type WanXDslInfo struct {
 	Wan struct {
 		XDsl struct {
-			State        string `json:"state"`
-			Modulation   string `json:"modulation"`
-			Showtime     int    `json:"showtime"`
-			ATURProvider string `json:"atur_provider"`
-			ATUCProcider string `json:"atuc_provider"`
-			SyncCount    int    `json:"sync_count"`
-			Up           struct {
-				Biterates   int `json:"bitrates"`
-				Noise       int `json:"noise"`
-				Attenuation int `json:"attenuation"`
-				Power       int `json:"power"`
-				PhyR        int `json:"phyr"`
-				GINP        int `json:"ginp"`
-				// Nitro       int `json:"nitro"` // Not enabled because in my case, it's an empty string (but it's the int 0 in the down struct, go figure why)
-				InterleaveDelay int `json:"interleave_delay"`
-			} `json:"up"`
-			Down struct {
-				Biterates       int `json:"bitrates"`
-				Noise           int `json:"noise"`
-				Attenuation     int `json:"attenuation"`
-				Power           int `json:"power"`
-				PhyR            int `json:"phyr"`
-				GINP            int `json:"ginp"`
-				Nitro           int `json:"nitro"`
-				InterleaveDelay int `json:"interleave_delay"`
-			} `json:"down"`
+			State        string        `json:"state"`
+			Modulation   string        `json:"modulation"`
+			Showtime     flexInt       `json:"showtime"`
+			ATURProvider string        `json:"atur_provider"`
+			ATUCProcider string        `json:"atuc_provider"`
+			SyncCount    flexInt       `json:"sync_count"`
+			Up           XDslDirection `json:"up"`
+			Down         XDslDirection `json:"down"`
 		} `json:"xdsl"`
 	} `json:"wan"`
 }
 
+// XDslDirection is the state of one direction of the xDsl line.
+// Depending on the firmware, fields are numbers, strings or empty strings.
+type XDslDirection struct {
+	Biterates       flexInt `json:"bitrates"`
+	Noise           flexInt `json:"noise"`       // SNR margin in 0.1 dB
+	Attenuation     flexInt `json:"attenuation"` // in 0.1 dB
+	Power           flexInt `json:"power"`       // in 0.1 dBm
+	PhyR            flexInt `json:"phyr"`
+	GINP            flexInt `json:"ginp"`
+	Nitro           flexInt `json:"nitro"`
+	InterleaveDelay flexInt `json:"interleave_delay"`
+}
+
 type WanIPInformations struct {
 	Wan struct {
 		Internet struct {
-			State int `json:"state"`
+			State flexInt `json:"state"`
 		} `json:"internet"`
 		Interface struct {
-			ID      int `json:"id"`
-			Default int `json:"default"`
-			State   int `json:"state"`
+			ID      flexInt `json:"id"`
+			Default flexInt `json:"default"`
+			State   flexInt `json:"state"`
 		} `json:"interface"`
 		IP struct {
 			Address    string        `json:"address"`
@@ -135,7 +150,7 @@ type WanIPInformations struct {
 			IP6Address []interface{} `json:"ip6address"`
 			IP6Prefix  []interface{} `json:"ip6prefix"`
 			Mac        string        `json:"mac"`
-			Mtu        int           `json:"mtu"`
+			Mtu        flexInt       `json:"mtu"`
 		} `json:"ip"`
 		Link struct {
 			State string `json:"state"`
@@ -146,39 +161,26 @@ type WanIPInformations struct {
 
 type WanDiagsStatistics struct {
 	Diags struct {
-		DNS []struct {
-			Min      float64 `json:"min"`
-			Max      float64 `json:"max"`
-			Average  float64 `json:"average"`
-			Success  int     `json:"success"`
-			Error    int     `json:"error"`
-			Tries    int     `json:"tries"`
-			Status   string  `json:"status"`
-			Protocol string  `json:"protocol"`
-		} `json:"dns"`
-		Ping []struct {
-			Min      float64 `json:"min"`
-			Max      float64 `json:"max"`
-			Average  float64 `json:"average"`
-			Success  int     `json:"success"`
-			Error    int     `json:"error"`
-			Tries    int     `json:"tries"`
-			Status   string  `json:"status"`
-			Protocol string  `json:"protocol"`
-		} `json:"ping"`
-		HTTP []struct {
-			Min      float64 `json:"min"`
-			Max      float64 `json:"max"`
-			Average  float64 `json:"average"`
-			Success  int     `json:"success"`
-			Error    int     `json:"error"`
-			Tries    int     `json:"tries"`
-			Status   string  `json:"status"`
-			Protocol string  `json:"protocol"`
-		} `json:"http"`
+		DNS  []WanDiagnostic `json:"dns"`
+		Ping []WanDiagnostic `json:"ping"`
+		HTTP []WanDiagnostic `json:"http"`
 	} `json:"diags"`
 }
 
+// WanDiagnostic is the result of a connectivity test run by the Bbox against
+// a host.
+type WanDiagnostic struct {
+	Host     string    `json:"host"`
+	Min      flexFloat `json:"min"`
+	Max      flexFloat `json:"max"`
+	Average  flexFloat `json:"average"`
+	Success  flexInt   `json:"success"`
+	Error    flexInt   `json:"error"`
+	Tries    flexInt   `json:"tries"`
+	Status   string    `json:"status"`
+	Protocol string    `json:"protocol"`
+}
+
 func (client *Client) getWanMetrics() (*WanMetrics, error) {
 	var metrics WanMetrics
 
@@ -188,23 +190,37 @@ func (client *Client) getWanMetrics() (*WanMetrics, error) {
 	}
 	metrics.IPInformations = wanIPInformations
 
-	wanIPStats, err := client.getWanStatistics()
+	wanIPStats, err := client.GetWanStatistics()
 	if err != nil {
 		return nil, err
 	}
 	metrics.IPStatistics = wanIPStats
 
-	ftthStats, err := client.getWanFtthStatistics()
+	diagsStats, err := client.GetWANDiagnostics()
 	if err != nil {
 		return nil, err
 	}
-	metrics.FtthStatistics = ftthStats
+	metrics.DiagnosticsStatistics = diagsStats
 
-	diagsStats, err := client.getWANDiagnostics()
-	if err != nil {
-		return nil, err
+	if metrics.IsFtth() {
+		level.Info(client.logger).Log("msg", "FTTH line, skip xDsl metrics")
+		ftthInfos, err := client.getWanFtthInformations()
+		if err != nil {
+			level.Warn(client.logger).Log("msg", "FTTH informations not available", "err", err)
+		}
+		metrics.FtthInformations = ftthInfos
+
+		ftthStats, err := client.getWanFtthStatistics()
+		if err != nil {
+			level.Warn(client.logger).Log("msg", "FTTH statistics not available", "err", err)
+		}
+		metrics.FtthStatistics = ftthStats
+		return &metrics, nil
+	}
+	if !client.supports(featureXDsl) {
+		level.Info(client.logger).Log("msg", "No xDsl line on this Bbox, skip xDsl metrics")
+		return &metrics, nil
 	}
-	metrics.DiagnosticsStatistics = diagsStats
 
 	xDslStats, err := client.getXDslStatistics()
 	if err != nil {
@@ -221,6 +237,11 @@ func (client *Client) getWanMetrics() (*WanMetrics, error) {
 	return &metrics, nil
 }
 
+// IsFtth returns true if the WAN link of the Bbox is a fiber.
+func (metrics *WanMetrics) IsFtth() bool {
+	return len(metrics.IPInformations) > 0 && strings.ToUpper(metrics.IPInformations[0].Wan.Link.Type) == "FTTH"
+}
+
 // getWanInformations returns WAN IP Information
 // See: https://api.bbox.fr/doc/apirouter/#api-WAN-GetWANIP
 func (client *Client) getWanInformations() ([]WanIPInformations, error) {
@@ -232,9 +253,9 @@ func (client *Client) getWanInformations() ([]WanIPInformations, error) {
 	return informations, nil
 }
 
-// getWanStatistics returns WAN IP statistics
+// GetWanStatistics returns WAN IP statistics
 // See: https://api.bbox.fr/doc/apirouter/#api-WAN-GetWANIPStats
-func (client *Client) getWanStatistics() ([]WanIPStatistics, error) {
+func (client *Client) GetWanStatistics() ([]WanIPStatistics, error) {
 	level.Info(client.logger).Log("msg", "Retrieve WAN metrics from Bbox")
 	var metrics []WanIPStatistics
 	if err := client.apiRequest("/wan/ip/stats", &metrics); err != nil {
@@ -243,20 +264,31 @@ func (client *Client) getWanStatistics() ([]WanIPStatistics, error) {
 	return metrics, nil
 }
 
-// getWanFtthStatistics returns information about FTTH
+// getWanFtthInformations returns the state of the FTTH link
+// See: https://api.bbox.fr/doc/apirouter/#api-WAN-GetFTTH
+func (client *Client) getWanFtthInformations() ([]FtthInformations, error) {
+	level.Info(client.logger).Log("msg", "Retrieve FTTH informations from Bbox")
+	var informations []FtthInformations
+	if err := client.apiRequest("/wan/ftth", &informations); err != nil {
+		return nil, err
+	}
+	return informations, nil
+}
+
+// getWanFtthStatistics returns statistics of the optical module
 // See: https://api.bbox.fr/doc/apirouter/#api-WAN-GetFTTHStats
-func (client *Client) getWanFtthStatistics() (*FtthStatistics, error) {
-	level.Info(client.logger).Log("msg", "Retrieve WAN metrics from Bbox")
-	var metrics FtthStatistics
+func (client *Client) getWanFtthStatistics() ([]FtthStatistics, error) {
+	level.Info(client.logger).Log("msg", "Retrieve FTTH statistics from Bbox")
+	var metrics []FtthStatistics
 	if err := client.apiRequest("/wan/ftth/stats", &metrics); err != nil {
 		return nil, err
 	}
-	return &metrics, nil
+	return metrics, nil
 }
 
-// getWANDiagnostics return results of the tests to retrieve the real state of the Internet connectivity
+// GetWANDiagnostics return results of the tests to retrieve the real state of the Internet connectivity
 // https://api.bbox.fr/doc/apirouter/index.html#api-WAN-GetWANDiags
-func (client *Client) getWANDiagnostics() ([]WanDiagsStatistics, error) {
+func (client *Client) GetWANDiagnostics() ([]WanDiagsStatistics, error) {
 	level.Info(client.logger).Log("msg", "Retrieve WAN diagnostics from Bbox")
 	var metrics []WanDiagsStatistics
 	if err := client.apiRequest("/wan/diags", &metrics); err != nil {
@@ -265,6 +297,52 @@ func (client *Client) getWANDiagnostics() ([]WanDiagsStatistics, error) {
 	return metrics, nil
 }
 
+// RunWANDiagnostics asks the Bbox to run the connectivity tests now, and waits
+// for their results. It returns ErrNotSupported if the firmware can't trigger them.
+func (client *Client) RunWANDiagnostics(timeout time.Duration) ([]WanDiagsStatistics, error) {
+	level.Info(client.logger).Log("msg", "Run WAN diagnostics on Bbox")
+	// The previous results are kept by the Bbox until the new run completes.
+	previous, err := client.GetWANDiagnostics()
+	if err != nil {
+		return nil, err
+	}
+	if err := client.apiWriteRequest("PUT", "/wan/diags", url.Values{}); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	started := false
+	for {
+		time.Sleep(2 * time.Second)
+		metrics, err := client.GetWANDiagnostics()
+		if err != nil {
+			return nil, err
+		}
+		if diagnosticsRunning(metrics) {
+			started = true
+		} else if started || !reflect.DeepEqual(metrics, previous) {
+			return metrics, nil
+		}
+		if time.Now().After(deadline) {
+			return metrics, fmt.Errorf("no new WAN diagnostics results after %s", timeout)
+		}
+	}
+}
+
+// diagnosticsRunning returns true while a test has not completed.
+func diagnosticsRunning(metrics []WanDiagsStatistics) bool {
+	for _, stats := range metrics {
+		for _, diagnostics := range [][]WanDiagnostic{stats.Diags.DNS, stats.Diags.Ping, stats.Diags.HTTP} {
+			for _, diagnostic := range diagnostics {
+				status := strings.ToLower(diagnostic.Status)
+				if strings.Contains(status, "progress") || strings.Contains(status, "running") || strings.Contains(status, "pending") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 // getXDslInformations returns information about xDsl
 // https://api.bbox.fr/doc/apirouter/index.html#api-WAN-GetWANXDSL
 func (client *Client) getXDslInformations() ([]WanXDslInfo, error) {
@@ -23,13 +23,52 @@ import (
 type WirelessMetrics struct {
 	Wireless5GhzStatistics  []WirelessStatistics
 	Wireless24GhzStatistics []WirelessStatistics
+	Wireless6GhzStatistics  []WirelessStatistics
+	AccessControl           WirelessAccessControl
+}
+
+// WirelessAccessControl gathers the settings restricting the access to the Wi-Fi
+type WirelessAccessControl struct {
+	Scheduler []WirelessScheduler `json:"scheduler"`
+	WPS       []WirelessWPS       `json:"wps"`
+	ACL       []WirelessACL       `json:"acl"`
+}
+
+// WirelessScheduler represents the time slots when the Wi-Fi is turned off
+type WirelessScheduler struct {
+	Wifischeduler struct {
+		Enable flexBool   `json:"enable"`
+		Rules  []Schedule `json:"rules"`
+	} `json:"wifischeduler"`
+}
+
+// WirelessWPS represents the state of the Wi-Fi Protected Setup
+type WirelessWPS struct {
+	WPS struct {
+		Enable  flexBool `json:"enable"`
+		Status  string   `json:"status"`
+		Timeout flexInt  `json:"timeout"`
+	} `json:"wps"`
+}
+
+// WirelessACL represents the MAC address filtering of the Wi-Fi
+type WirelessACL struct {
+	ACL struct {
+		Enable flexBool `json:"enable"`
+		Mode   string   `json:"mode"`
+		Rules  []struct {
+			ID         flexInt  `json:"id"`
+			Enable     flexBool `json:"enable"`
+			Macaddress string   `json:"macaddress"`
+		} `json:"rules"`
+	} `json:"acl"`
 }
 
 // WirelessStatistics represents statistics information of the Bbox WIFI
 type WirelessStatistics struct {
 	Wireless struct {
 		SSID struct {
-			ID    interface{} `json:"id"`
+			ID    flexInt `json:"id"`
 			Stats struct {
 				Rx struct {
 					Packets         flexInt `json:"packets"`
@@ -63,9 +102,44 @@ func (client *Client) getWirelessMetrics() (*WirelessMetrics, error) {
 	}
 	metrics.Wireless24GhzStatistics = wifi24Ghz
 
+	if client.supports(featureRadio6GHz) {
+		wifi6Ghz, err := client.getWirelessStatistics("6")
+		client.checkSupported(featureRadio6GHz, err)
+		if err != nil {
+			level.Warn(client.logger).Log("msg", "6 GHz Wi-Fi statistics not available", "err", err)
+		}
+		metrics.Wireless6GhzStatistics = wifi6Ghz
+	}
+
+	accessControl, err := client.GetWirelessAccessControl()
+	if err != nil {
+		return nil, err
+	}
+	metrics.AccessControl = *accessControl
+
 	return &metrics, nil
 }
 
+// GetWirelessAccessControl returns the scheduler, WPS and ACL settings of the Wi-Fi.
+// Each of them is optional, as not every firmware provides them.
+func (client *Client) GetWirelessAccessControl() (*WirelessAccessControl, error) {
+	var accessControl WirelessAccessControl
+
+	// See: https://api.bbox.fr/doc/apirouter/#api-Wireless-GetWirelessScheduler
+	if err := client.apiRequest("/wireless/scheduler", &accessControl.Scheduler); err != nil {
+		level.Warn(client.logger).Log("msg", "WIFI scheduler not available", "err", err)
+	}
+	// See: https://api.bbox.fr/doc/apirouter/#api-Wireless-GetWPS
+	if err := client.apiRequest("/wireless/wps", &accessControl.WPS); err != nil {
+		level.Warn(client.logger).Log("msg", "WIFI WPS not available", "err", err)
+	}
+	// See: https://api.bbox.fr/doc/apirouter/#api-Wireless-GetWirelessACL
+	if err := client.apiRequest("/wireless/acl", &accessControl.ACL); err != nil {
+		level.Warn(client.logger).Log("msg", "WIFI ACL not available", "err", err)
+	}
+	return &accessControl, nil
+}
+
 func (client *Client) getWirelessStatistics(which string) ([]WirelessStatistics, error) {
 	level.Info(client.logger).Log("msg", "Retrieve WIFI %sGhz metrics from Bbox", which)
 
@@ -0,0 +1,68 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbox
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// WakeOnLAN sends a Wake-on-LAN to a host of the LAN through the Wake-on-LAN
+// proxy of the Bbox. The host must be known by the Bbox.
+// See: https://api.bbox.fr/doc/apirouter/#api-Remote-WakeOnLan
+func (client *Client) WakeOnLAN(mac string) error {
+	host, err := client.getLanHost(mac)
+	if err != nil {
+		return err
+	}
+	level.Info(client.logger).Log("msg", "Wake-on-LAN", "mac", host.Macaddress, "hostname", host.Hostname)
+	values := url.Values{}
+	values.Set("macaddress", host.Macaddress)
+	return client.apiWriteRequest("POST", "/remote/proxywol/wake", values)
+}
+
+// CheckLanHost checks that the host with the given MAC address is known by the Bbox.
+func (client *Client) CheckLanHost(mac string) error {
+	_, err := client.getLanHost(mac)
+	return err
+}
+
+// getLanHost returns the host of the LAN with the given MAC address.
+func (client *Client) getLanHost(mac string) (LanHost, error) {
+	devices, err := client.getLanDevices()
+	if err != nil {
+		return LanHost{}, err
+	}
+	host, ok := findLanHost(devices, mac)
+	if !ok {
+		return LanHost{}, fmt.Errorf("%s: %w", mac, ErrUnknownHost)
+	}
+	return host, nil
+}
+
+// findLanHost returns the host with the given MAC address.
+func findLanHost(devices []LanDevice, mac string) (LanHost, bool) {
+	for _, device := range devices {
+		for _, host := range device.Hosts.List {
+			if strings.EqualFold(host.Macaddress, mac) {
+				return host, true
+			}
+		}
+	}
+	return LanHost{}, false
+}
@@ -18,8 +18,11 @@ import (
 	// "flag"
 
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
@@ -30,7 +33,9 @@ import (
 	"github.com/prometheus/exporter-toolkit/web"
 	webflag "github.com/prometheus/exporter-toolkit/web/kingpinflag"
 	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
 
+	"github.com/nlamirault/bbox_exporter/bbox"
 	"github.com/nlamirault/bbox_exporter/exporter"
 )
 
@@ -56,6 +61,104 @@ var (
 		"web.telemetry-path",
 		"Path under which to expose metrics.",
 	).OverrideDefaultFromEnvar("BBOX_EXPORTER_METRICS_PATH").Default("/metrics").String()
+	compatGaugeNames = kingpin.Flag(
+		"compat.gauge-names",
+		"Also export counters as gauges under their names before the _total suffix (deprecated).",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_COMPAT_GAUGE_NAMES").Default("false").Bool()
+	throughputInterval = kingpin.Flag(
+		"wan.throughput-interval",
+		"Interval to poll the WAN statistics for the throughput. 0 computes it between scrapes.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_WAN_THROUGHPUT_INTERVAL").Default("0s").Duration()
+	snapshotMaxAge = kingpin.Flag(
+		"web.snapshot-max-age",
+		"Maximum age of the last scrape served by the InfluxDB and JSON endpoints. An older one triggers a new scrape of the Bbox.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_SNAPSHOT_MAX_AGE").Default("1m").Duration()
+	lite = kingpin.Flag(
+		"collector.lite",
+		"Build the core metrics from the /summary endpoint only, for frequent scrapes of the state of the Bbox.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_COLLECTOR_LITE").Default("false").Bool()
+	enableDiagnose = kingpin.Flag(
+		"web.enable-diagnose",
+		"Enable the /diagnose endpoints, which run the WAN diagnostics of the Bbox on demand. Requires basic authentication in --web.config.file.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_ENABLE_DIAGNOSE").Default("false").Bool()
+	enableWirelessInventory = kingpin.Flag(
+		"web.enable-wireless-inventory",
+		"Enable the /wireless/inventory endpoint, which returns the Wi-Fi settings and MAC address filtering entries of the Bbox. Requires basic authentication in --web.config.file.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_ENABLE_WIRELESS_INVENTORY").Default("false").Bool()
+	enableWOL = kingpin.Flag(
+		"web.enable-wol",
+		"Enable the /actions/wol endpoint, which sends a Wake-on-LAN through the Bbox. Requires basic authentication in --web.config.file.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_ENABLE_WOL").Default("false").Bool()
+	enableReboot = kingpin.Flag(
+		"web.enable-reboot",
+		"Enable the /actions/reboot endpoint, which reboots the Bbox. Requires basic authentication in --web.config.file.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_ENABLE_REBOOT").Default("false").Bool()
+	actionInterval = kingpin.Flag(
+		"actions.min-interval",
+		"Minimum interval between two runs of an action on the same target.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_ACTIONS_MIN_INTERVAL").Default("5m").Duration()
+	auditLog = kingpin.Flag(
+		"actions.audit-log",
+		"File where the actions are appended as JSON lines, in addition to the logs.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_ACTIONS_AUDIT_LOG").String()
+	mqttBroker = kingpin.Flag(
+		"mqtt.broker",
+		"URL of the MQTT broker where the state of the Bbox is published, e.g. tcp://localhost:1883. Disabled when empty.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_MQTT_BROKER").String()
+	mqttClientID = kingpin.Flag(
+		"mqtt.client-id",
+		"Client ID of the exporter on the MQTT broker.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_MQTT_CLIENT_ID").Default("bbox_exporter").String()
+	mqttUsername = kingpin.Flag(
+		"mqtt.username",
+		"Username on the MQTT broker.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_MQTT_USERNAME").String()
+	mqttPassword = kingpin.Flag(
+		"mqtt.password",
+		"Password on the MQTT broker.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_MQTT_PASSWORD").String()
+	mqttTopicPrefix = kingpin.Flag(
+		"mqtt.topic-prefix",
+		"Prefix of the MQTT topics of the state of the Bbox.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_MQTT_TOPIC_PREFIX").Default("bbox").String()
+	mqttDiscoveryPrefix = kingpin.Flag(
+		"mqtt.discovery-prefix",
+		"Prefix of the Home Assistant discovery topics.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_MQTT_DISCOVERY_PREFIX").Default("homeassistant").String()
+	mqttInterval = kingpin.Flag(
+		"mqtt.interval",
+		"Interval to publish the state of the Bbox to the MQTT broker.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_MQTT_INTERVAL").Default("1m").Duration()
+	knownHostsFile = kingpin.Flag(
+		"presence.known-hosts",
+		"File of the MAC addresses expected on the network, one per line. Without it, the hosts of the first scrape are known.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_PRESENCE_KNOWN_HOSTS").String()
+	presenceWebhook = kingpin.Flag(
+		"presence.webhook-url",
+		"URL where the unknown hosts joining the network are posted as JSON.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_PRESENCE_WEBHOOK_URL").String()
+	hostExpiry = kingpin.Flag(
+		"presence.host-expiry",
+		"Time after which a host not seen active on the network is forgotten. 0 keeps the hosts forever.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_PRESENCE_HOST_EXPIRY").Default("168h").Duration()
+	recordDir = kingpin.Flag(
+		"record.dir",
+		"Directory where the responses of the Bbox are saved, with the MAC addresses, IP addresses, host names, SSIDs, phone numbers and serials redacted.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_RECORD_DIR").String()
+	recordKey = kingpin.Flag(
+		"record.key",
+		"Secret key of the fake values of --record.dir. The same key gives the same fake values at each recording. Random when empty.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_RECORD_KEY").String()
+	replayDir = kingpin.Flag(
+		"replay.dir",
+		"Directory of responses saved with --record.dir, replayed instead of querying the Bbox.",
+	).OverrideDefaultFromEnvar("BBOX_EXPORTER_REPLAY_DIR").String()
+
+	serveCmd = kingpin.Command("serve", "Run the exporter.").Default()
+	getCmd   = kingpin.Command("get", "Print an endpoint of the Bbox API as JSON.")
+	getPath  = getCmd.Arg("path", "Path of the endpoint, e.g. /device.").Required().String()
+	hostsCmd = kingpin.Command("hosts", "Print the hosts known by the Bbox.")
+	checkCmd = kingpin.Command("check", "Scrape the Bbox once, print the metrics and fail if the scrape fails.")
 )
 
 func main() {
@@ -64,13 +167,80 @@ func main() {
 	flag.AddFlags(kingpin.CommandLine, promlogConfig)
 	kingpin.Version(version.Print("bbox_exporter"))
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	command := kingpin.Parse()
 	logger := promlog.New(promlogConfig)
 
+	transport, err := newTransport()
+	if err != nil {
+		level.Error(logger).Log("msg", "Can't record the responses", "err", err)
+		os.Exit(1)
+	}
+
+	switch command {
+	case getCmd.FullCommand():
+		os.Exit(runGet(logger, transport, *getPath))
+	case hostsCmd.FullCommand():
+		os.Exit(runHosts(logger, transport))
+	case checkCmd.FullCommand():
+		os.Exit(runCheck(logger, exporter.Options{CompatGaugeNames: *compatGaugeNames, Transport: transport, Lite: *lite}))
+	}
+
 	level.Info(logger).Log("msg", "Starting bbox_exporter", "version", version.Info())
 	level.Info(logger).Log("msg", "Build context", "context", version.BuildContext())
 
-	exporter, err := exporter.NewExporter(*endpoint, *password, logger)
+	options := exporter.Options{
+		CompatGaugeNames:   *compatGaugeNames,
+		ThroughputInterval: *throughputInterval,
+		EnableWOL:          *enableWOL,
+		EnableReboot:       *enableReboot,
+		ActionInterval:     *actionInterval,
+		Transport:          transport,
+		Lite:               *lite,
+		SnapshotMaxAge:     *snapshotMaxAge,
+		MQTT: exporter.MQTTOptions{
+			Broker:          *mqttBroker,
+			ClientID:        *mqttClientID,
+			Username:        *mqttUsername,
+			Password:        *mqttPassword,
+			TopicPrefix:     *mqttTopicPrefix,
+			DiscoveryPrefix: *mqttDiscoveryPrefix,
+			Interval:        *mqttInterval,
+		},
+		PresenceWebhook: *presenceWebhook,
+		HostExpiry:      *hostExpiry,
+	}
+	if *knownHostsFile != "" {
+		knownHosts, err := readKnownHosts(*knownHostsFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Can't read known hosts", "err", err)
+			os.Exit(1)
+		}
+		options.KnownHosts = knownHosts
+	}
+	if *enableWOL || *enableReboot || *enableDiagnose || *enableWirelessInventory {
+		auth, err := basicAuthEnabled(*webConfig)
+		if err != nil {
+			level.Error(logger).Log("msg", "Invalid web configuration", "err", err)
+			os.Exit(1)
+		}
+		if !auth {
+			level.Error(logger).Log("msg", "The actions, diagnose and inventory endpoints require basic authentication in the web configuration")
+			os.Exit(1)
+		}
+	}
+	if *enableWOL || *enableReboot {
+		if *auditLog != "" {
+			file, err := os.OpenFile(*auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				level.Error(logger).Log("msg", "Can't open audit log", "err", err)
+				os.Exit(1)
+			}
+			defer file.Close()
+			options.AuditLog = file
+		}
+	}
+
+	exporter, err := exporter.NewExporter(*endpoint, *password, options, logger)
 	if err != nil {
 		level.Error(logger).Log("msg", "Can't create exporter", "err", err)
 		os.Exit(1)
@@ -91,12 +261,34 @@ func main() {
 			),
 		),
 	)
+	http.Handle(*metricPath+"/influx", exporter.InfluxHandler())
+	http.Handle(*metricPath+"/json", exporter.JSONHandler())
+	if *enableWirelessInventory {
+		http.Handle("/wireless/inventory", exporter.WirelessInventoryHandler())
+	}
+	if *enableDiagnose {
+		http.Handle("/diagnose", exporter.DiagnoseHandler())
+		http.Handle("/diagnose/metrics", exporter.DiagnoseMetricsHandler())
+	}
+	if *enableWOL {
+		http.Handle("/actions/wol", exporter.WakeOnLANHandler())
+	}
+	if *enableReboot {
+		http.Handle("/actions/reboot", exporter.RebootHandler())
+	}
+	inventoryLink := ""
+	if *enableWirelessInventory {
+		inventoryLink = `<p><a href='/wireless/inventory'>Wi-Fi inventory</a></p>`
+	}
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>BBox Exporter</title></head>
              <body>
              <h1>BBox Exporter</h1>
              <p><a href='` + *metricPath + `'>Metrics</a></p>
+             <p><a href='` + *metricPath + `/influx'>Metrics in InfluxDB line protocol</a></p>
+             <p><a href='` + *metricPath + `/json'>Metrics as JSON</a></p>
+             ` + inventoryLink + `
 			 <h2>Build</h2>
              <pre>` + version.Info() + ` ` + version.BuildContext() + `</pre>
              </body>
@@ -118,3 +310,60 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// basicAuthEnabled returns true if the web configuration file requires
+// basic authentication.
+func basicAuthEnabled(path string) (bool, error) {
+	if path == "" {
+		return false, nil
+	}
+	if err := web.Validate(path); err != nil {
+		return false, err
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	var config struct {
+		Users map[string]string `yaml:"basic_auth_users"`
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return false, err
+	}
+	return len(config.Users) > 0, nil
+}
+
+// readKnownHosts returns the MAC addresses of a file, one per line. Empty
+// lines and lines starting with # are ignored.
+func readKnownHosts(path string) ([]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		mac, err := net.ParseMAC(line)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, mac.String())
+	}
+	return hosts, nil
+}
+
+// newTransport returns the transport of the requests to the Bbox, which
+// records or replays the responses. It is nil for the default transport.
+func newTransport() (http.RoundTripper, error) {
+	var transport http.RoundTripper
+	if *replayDir != "" {
+		transport = bbox.NewReplayTransport(*replayDir)
+	}
+	if *recordDir != "" {
+		return bbox.NewRecordTransport(*recordDir, *recordKey, transport)
+	}
+	return transport, nil
+}
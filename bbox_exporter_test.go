@@ -0,0 +1,119 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/exporter-toolkit/web"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+	"github.com/nlamirault/bbox_exporter/exporter"
+)
+
+// The password of the admin user is "secret".
+const authWebConfig = `basic_auth_users:
+  admin: $2a$04$eYS6Gk8bbRTGwwBA2oO6xudknv3QtKqxgqk2OMkDPixc7GisF/rnq
+`
+
+func writeWebConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "web.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBasicAuthEnabled(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		want   bool
+	}{
+		{"no configuration", "", false},
+		{"basic authentication", authWebConfig, true},
+		{"without users", "basic_auth_users: {}\n", false},
+	} {
+		path := ""
+		if tc.config != "" {
+			path = writeWebConfig(t, tc.config)
+		}
+		got, err := basicAuthEnabled(path)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: basic authentication = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+	if _, err := basicAuthEnabled(writeWebConfig(t, "basic_auth_users: [admin]\n")); err == nil {
+		t.Error("invalid configuration accepted")
+	}
+}
+
+func TestActionsBasicAuth(t *testing.T) {
+	e, err := exporter.NewExporter("https://bbox.test", "password", exporter.Options{
+		Transport:    bbox.NewReplayTransport("exporter/testdata/bbox"),
+		EnableWOL:    true,
+		EnableReboot: true,
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/actions/wol", e.WakeOnLANHandler())
+	mux.Handle("/actions/reboot", e.RebootHandler())
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: mux}
+	go web.Serve(listener, server, writeWebConfig(t, authWebConfig), log.NewNopLogger())
+	defer server.Close()
+
+	for _, path := range []string{"/actions/wol?mac=02:2f:50:88:71:3a&dry_run=true", "/actions/reboot?dry_run=true"} {
+		for _, tc := range []struct {
+			name     string
+			user     string
+			password string
+			auth     bool
+		}{
+			{"without credentials", "", "", false},
+			{"wrong password", "admin", "password", false},
+			{"admin", "admin", "secret", true},
+		} {
+			request, err := http.NewRequest(http.MethodPost, "http://"+listener.Addr().String()+path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.user != "" {
+				request.SetBasicAuth(tc.user, tc.password)
+			}
+			resp, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if unauthorized := resp.StatusCode == http.StatusUnauthorized; unauthorized == tc.auth {
+				t.Errorf("%s %s: status %d", path, tc.name, resp.StatusCode)
+			}
+		}
+	}
+}
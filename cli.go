@@ -0,0 +1,125 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+	"github.com/nlamirault/bbox_exporter/exporter"
+)
+
+// newClient returns an authenticated client of the Bbox API.
+func newClient(logger log.Logger, transport http.RoundTripper) (*bbox.Client, error) {
+	client, err := bbox.NewClient(*endpoint, *password, logger)
+	if err != nil {
+		return nil, err
+	}
+	if transport != nil {
+		client.SetTransport(transport)
+	}
+	if err := client.Authenticate(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// runGet prints an endpoint of the Bbox API as indented JSON.
+func runGet(logger log.Logger, transport http.RoundTripper, path string) int {
+	client, err := newClient(logger, transport)
+	if err != nil {
+		level.Error(logger).Log("msg", "Bbox authentication error", "err", err)
+		return 1
+	}
+	raw, err := client.GetRaw(path)
+	if err != nil {
+		level.Error(logger).Log("msg", "Bbox API error", "path", path, "err", err)
+		return 1
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, raw, "", "  "); err != nil {
+		level.Error(logger).Log("msg", "Invalid JSON response", "path", path, "err", err)
+		return 1
+	}
+	fmt.Println(out.String())
+	return 0
+}
+
+// runHosts prints the hosts known by the Bbox as a table.
+func runHosts(logger log.Logger, transport http.RoundTripper) int {
+	client, err := newClient(logger, transport)
+	if err != nil {
+		level.Error(logger).Log("msg", "Bbox authentication error", "err", err)
+		return 1
+	}
+	hosts, err := client.GetLanHosts()
+	if err != nil {
+		level.Error(logger).Log("msg", "Bbox API error", "err", err)
+		return 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOSTNAME\tMAC\tIP\tLINK\tTYPE\tACTIVE")
+	for _, host := range hosts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\n",
+			host.Hostname, host.Macaddress, host.Ipaddress, host.Link, host.Devicetype, host.Active)
+	}
+	if err := w.Flush(); err != nil {
+		level.Error(logger).Log("msg", "Can't print hosts", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// runCheck scrapes the Bbox once and prints the metrics. It fails if the
+// Bbox can't be scraped.
+func runCheck(logger log.Logger, options exporter.Options) int {
+	exp, err := exporter.NewExporter(*endpoint, *password, options, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "Can't create exporter", "err", err)
+		return 1
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exp)
+	families, err := registry.Gather()
+	if err != nil {
+		level.Error(logger).Log("msg", "Can't gather metrics", "err", err)
+		return 1
+	}
+	up := false
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(os.Stdout, family); err != nil {
+			level.Error(logger).Log("msg", "Can't print metrics", "err", err)
+			return 1
+		}
+		if family.GetName() == "bbox_up" && len(family.Metric) > 0 {
+			up = family.Metric[0].GetGauge().GetValue() == 1
+		}
+	}
+	if !up {
+		level.Error(logger).Log("msg", "Bbox scrape failed")
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,207 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var actionsTotal = newCounter("actions_total", "Number of actions triggered through the exporter", []string{"action", "result"})
+
+// errRateLimited is returned when an action is triggered again before the
+// minimum interval between two actions.
+var errRateLimited = errors.New("action rate limited")
+
+// auditEntry records who triggered an action, and when.
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Target string    `json:"target,omitempty"`
+	User   string    `json:"user,omitempty"`
+	Remote string    `json:"remote"`
+	DryRun bool      `json:"dry_run,omitempty"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+}
+
+type actionKey struct {
+	action string
+	result string
+}
+
+// actionRecorder rate limits the actions, counts them and writes the audit log.
+type actionRecorder struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[string]time.Time
+	counts   map[actionKey]float64
+	audit    io.Writer
+}
+
+func newActionRecorder(interval time.Duration, audit io.Writer) *actionRecorder {
+	return &actionRecorder{
+		interval: interval,
+		last:     map[string]time.Time{},
+		counts:   map[actionKey]float64{},
+		audit:    audit,
+	}
+}
+
+// allow returns true if the action on the target was not triggered during the
+// minimum interval, and takes the slot of the action.
+func (a *actionRecorder) allow(action string, target string, at time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := action + "/" + target
+	if last, ok := a.last[key]; ok && at.Sub(last) < a.interval {
+		return false
+	}
+	a.last[key] = at
+	return true
+}
+
+// release frees the slot taken at the given time by an action which failed,
+// so it can be retried at once.
+func (a *actionRecorder) release(action string, target string, at time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := action + "/" + target
+	if last, ok := a.last[key]; ok && last.Equal(at) {
+		delete(a.last, key)
+	}
+}
+
+// record counts the action and appends it to the audit log.
+func (a *actionRecorder) record(entry auditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.counts[actionKey{entry.Action, entry.Result}]++
+	if a.audit == nil {
+		return nil
+	}
+	return json.NewEncoder(a.audit).Encode(entry)
+}
+
+func (a *actionRecorder) values() map[actionKey]float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	values := make(map[actionKey]float64, len(a.counts))
+	for key, count := range a.counts {
+		values[key] = count
+	}
+	return values
+}
+
+func (e *Exporter) actionsEnabled() bool {
+	return e.options.EnableWOL || e.options.EnableReboot
+}
+
+func (e *Exporter) describeActionsMetrics(ch chan<- *prometheus.Desc) {
+	if e.actionsEnabled() {
+		e.describeMetric(ch, actionsTotal)
+	}
+}
+
+func (e *Exporter) storeActionsMetrics(ch chan<- prometheus.Metric) {
+	if !e.actionsEnabled() {
+		return
+	}
+	for key, count := range e.actions.values() {
+		e.storeMetric(ch, count, actionsTotal, key.action, key.result)
+	}
+}
+
+// auditAction records an action triggered by the request.
+func (e *Exporter) auditAction(r *http.Request, entry *auditEntry) {
+	entry.Time = time.Now()
+	entry.User, _, _ = r.BasicAuth()
+	entry.Remote = r.RemoteAddr
+	level.Info(e.logger).Log("msg", "Audit", "action", entry.Action, "target", entry.Target, "user", entry.User,
+		"remote", entry.Remote, "dry_run", entry.DryRun, "result", entry.Result, "err", entry.Error)
+	if err := e.actions.record(*entry); err != nil {
+		level.Error(e.logger).Log("msg", "Can't write audit log", "err", err)
+	}
+}
+
+// writeAction writes the audit entry of an action as the JSON response.
+func (e *Exporter) writeAction(w http.ResponseWriter, status int, entry auditEntry) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		level.Error(e.logger).Log("msg", "Can't encode action result", "err", err)
+	}
+}
+
+// RebootHandler reboots the Bbox. Only POST requests are accepted. With the
+// dry_run parameter, it only checks that the Bbox would accept the reboot.
+func (e *Exporter) RebootHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		dryRun, _ := strconv.ParseBool(r.FormValue("dry_run"))
+		entry := auditEntry{Action: "reboot", DryRun: dryRun}
+		result, status, err := e.reboot(dryRun)
+		entry.Result = result
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		e.auditAction(r, &entry)
+		e.writeAction(w, status, entry)
+	})
+}
+
+// reboot reboots the Bbox and returns the result, with the HTTP status of
+// the response.
+func (e *Exporter) reboot(dryRun bool) (string, int, error) {
+	if err := e.Bbox.Authenticate(); err != nil {
+		return "error", http.StatusBadGateway, err
+	}
+	if dryRun {
+		if err := e.Bbox.CheckWriteAccess(); err != nil {
+			return "error", http.StatusBadGateway, err
+		}
+		return "dry_run", http.StatusOK, nil
+	}
+	now := time.Now()
+	if !e.actions.allow("reboot", "", now) {
+		return "rate_limited", http.StatusTooManyRequests, errRateLimited
+	}
+	err := e.Bbox.Reboot()
+	if err != nil {
+		e.actions.release("reboot", "", now)
+	}
+	switch {
+	case err == nil:
+		return "success", http.StatusAccepted, nil
+	case errors.Is(err, bbox.ErrNotSupported):
+		return "not_supported", http.StatusNotImplemented, err
+	default:
+		return "error", http.StatusBadGateway, err
+	}
+}
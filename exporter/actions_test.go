@@ -0,0 +1,237 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+// actionTransport answers the write requests of the actions with the given
+// status, and counts them. The other requests get the recorded responses.
+type actionTransport struct {
+	status int
+	writes int
+}
+
+func (t *actionTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body := ""
+	switch {
+	case strings.HasSuffix(request.URL.Path, "/device/token"):
+		body = `[{"device":{"token":"test"}}]`
+	case request.Method != http.MethodGet && !strings.HasSuffix(request.URL.Path, "/login"):
+		t.writes++
+		return &http.Response{
+			StatusCode: t.status,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+			Request:    request,
+		}, nil
+	default:
+		return bbox.NewReplayTransport(fixturesDir).RoundTrip(request)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    request,
+	}, nil
+}
+
+// newActionExporter returns an exporter with the actions enabled, and its
+// audit log.
+func newActionExporter(t *testing.T, transport *actionTransport) (*Exporter, *bytes.Buffer) {
+	audit := &bytes.Buffer{}
+	e, err := NewExporter("https://bbox.test", "password", Options{
+		Transport:      transport,
+		EnableWOL:      true,
+		EnableReboot:   true,
+		ActionInterval: time.Hour,
+		AuditLog:       audit,
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, audit
+}
+
+// postAction sends an action request as the admin user, and returns the
+// status and the audit entry of the response.
+func postAction(t *testing.T, handler http.Handler, target string) (int, auditEntry) {
+	request := httptest.NewRequest(http.MethodPost, target, nil)
+	request.SetBasicAuth("admin", "secret")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	var entry auditEntry
+	if err := json.Unmarshal(recorder.Body.Bytes(), &entry); err != nil {
+		t.Fatalf("%s: %s: %q", target, err, recorder.Body.String())
+	}
+	return recorder.Code, entry
+}
+
+// auditLines returns the entries of the audit log.
+func auditLines(t *testing.T, audit *bytes.Buffer) []auditEntry {
+	var entries []auditEntry
+	scanner := bufio.NewScanner(bytes.NewReader(audit.Bytes()))
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("audit line %q: %s", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRebootHandler(t *testing.T) {
+	transport := &actionTransport{status: http.StatusOK}
+	e, audit := newActionExporter(t, transport)
+	handler := e.RebootHandler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/actions/reboot", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+
+	for _, tc := range []struct {
+		target string
+		status int
+		result string
+	}{
+		{"/actions/reboot?dry_run=true", http.StatusOK, "dry_run"},
+		{"/actions/reboot", http.StatusAccepted, "success"},
+		{"/actions/reboot", http.StatusTooManyRequests, "rate_limited"},
+	} {
+		status, entry := postAction(t, handler, tc.target)
+		if status != tc.status || entry.Result != tc.result {
+			t.Errorf("%s = %d %q, want %d %q", tc.target, status, entry.Result, tc.status, tc.result)
+		}
+	}
+	if transport.writes != 1 {
+		t.Errorf("%d reboots sent to the Bbox, want 1", transport.writes)
+	}
+
+	entries := auditLines(t, audit)
+	if len(entries) != 3 {
+		t.Fatalf("%d audit lines, want 3", len(entries))
+	}
+	if entry := entries[0]; entry.Action != "reboot" || entry.User != "admin" || !entry.DryRun || entry.Result != "dry_run" || entry.Time.IsZero() {
+		t.Errorf("audit line = %+v", entry)
+	}
+	if entry := entries[2]; entry.Result != "rate_limited" || entry.Error == "" {
+		t.Errorf("audit line = %+v", entry)
+	}
+
+	samples := gatherSamples(t, e)
+	for series, want := range map[string]float64{
+		`bbox_actions_total{action="reboot",result="dry_run"}`:      1,
+		`bbox_actions_total{action="reboot",result="success"}`:      1,
+		`bbox_actions_total{action="reboot",result="rate_limited"}`: 1,
+	} {
+		if got := samples[series]; got != want {
+			t.Errorf("%s = %g, want %g", series, got, want)
+		}
+	}
+}
+
+func TestRebootHandlerFailure(t *testing.T) {
+	transport := &actionTransport{status: http.StatusInternalServerError}
+	e, _ := newActionExporter(t, transport)
+	handler := e.RebootHandler()
+
+	if status, entry := postAction(t, handler, "/actions/reboot"); status != http.StatusBadGateway || entry.Result != "error" {
+		t.Errorf("failed reboot = %d %q, want %d error", status, entry.Result, http.StatusBadGateway)
+	}
+	// The failed reboot doesn't take the slot of the action.
+	transport.status = http.StatusOK
+	if status, entry := postAction(t, handler, "/actions/reboot"); status != http.StatusAccepted || entry.Result != "success" {
+		t.Errorf("retried reboot = %d %q, want %d success", status, entry.Result, http.StatusAccepted)
+	}
+}
+
+func TestWakeOnLANHandler(t *testing.T) {
+	transport := &actionTransport{status: http.StatusOK}
+	e, audit := newActionExporter(t, transport)
+	handler := e.WakeOnLANHandler()
+
+	for _, tc := range []struct {
+		name   string
+		target string
+		code   int
+		result string
+	}{
+		{"invalid MAC", "/actions/wol?mac=host", http.StatusBadRequest, "invalid"},
+		{"unknown host", "/actions/wol?mac=02:00:00:00:00:01", http.StatusNotFound, "unknown_host"},
+		{"dry run", "/actions/wol?mac=02:2f:50:88:71:3a&dry_run=true", http.StatusOK, "dry_run"},
+		{"wake", "/actions/wol?mac=02:2f:50:88:71:3a", http.StatusOK, "success"},
+		{"wake again", "/actions/wol?mac=02:2F:50:88:71:3A", http.StatusTooManyRequests, "rate_limited"},
+		{"wake another host", "/actions/wol?mac=02:13:bb:e2:6e:f5", http.StatusOK, "success"},
+	} {
+		code, entry := postAction(t, handler, tc.target)
+		if code != tc.code || entry.Result != tc.result {
+			t.Errorf("%s = %d %q, want %d %q", tc.name, code, entry.Result, tc.code, tc.result)
+		}
+	}
+	if transport.writes != 2 {
+		t.Errorf("%d Wake-on-LAN sent to the Bbox, want 2", transport.writes)
+	}
+
+	entries := auditLines(t, audit)
+	if len(entries) != 6 {
+		t.Fatalf("%d audit lines, want 6", len(entries))
+	}
+	if entry := entries[3]; entry.Action != "wol" || entry.Target != "02:2f:50:88:71:3a" || entry.User != "admin" || entry.Result != "success" {
+		t.Errorf("audit line = %+v", entry)
+	}
+
+	samples := gatherSamples(t, e)
+	for series, want := range map[string]float64{
+		`bbox_actions_total{action="wol",result="invalid"}`:      1,
+		`bbox_actions_total{action="wol",result="unknown_host"}`: 1,
+		`bbox_actions_total{action="wol",result="success"}`:      2,
+		`bbox_actions_total{action="wol",result="rate_limited"}`: 1,
+	} {
+		if got := samples[series]; got != want {
+			t.Errorf("%s = %g, want %g", series, got, want)
+		}
+	}
+}
+
+func TestWakeOnLANHandlerFailure(t *testing.T) {
+	transport := &actionTransport{status: http.StatusInternalServerError}
+	e, _ := newActionExporter(t, transport)
+	handler := e.WakeOnLANHandler()
+
+	if code, entry := postAction(t, handler, "/actions/wol?mac=02:2f:50:88:71:3a"); code != http.StatusBadGateway || entry.Result != "error" {
+		t.Errorf("failed Wake-on-LAN = %d %q, want %d error", code, entry.Result, http.StatusBadGateway)
+	}
+	// The failed Wake-on-LAN doesn't take the slot of the host.
+	transport.status = http.StatusOK
+	if code, entry := postAction(t, handler, "/actions/wol?mac=02:2f:50:88:71:3a"); code != http.StatusOK || entry.Result != "success" {
+		t.Errorf("retried Wake-on-LAN = %d %q, want %d success", code, entry.Result, http.StatusOK)
+	}
+}
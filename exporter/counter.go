@@ -0,0 +1,106 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strings"
+	"sync"
+)
+
+// The Bbox firmware stores traffic counters on 32 bits.
+// See: https://github.com/nlamirault/bbox_exporter/issues/1
+const counterWrap = float64(1 << 32)
+
+// maxWrapDistance is the largest increase of a counter across a wrap. A
+// larger decrease is a reset of the counter, e.g. after a WAN reconnection.
+const maxWrapDistance = counterWrap / 2
+
+// trackedCounter is the state kept for one counter series.
+type trackedCounter struct {
+	last   float64
+	offset float64
+}
+
+// counterTracker extends the 32-bit counters of the Bbox into monotonic
+// 64-bit counters. A counter going backwards from near 2^32 to a small value
+// is a wrap. Any other decrease, or a reboot of the Bbox, is a reset.
+type counterTracker struct {
+	mu       sync.Mutex
+	uptime   float64
+	reboots  float64
+	counters map[string]*trackedCounter
+	wraps    map[string]float64
+}
+
+func newCounterTracker() *counterTracker {
+	return &counterTracker{
+		counters: map[string]*trackedCounter{},
+		wraps:    map[string]float64{},
+	}
+}
+
+// observeUptime records the uptime of the Bbox. It must be called before
+// the counters of a scrape are extended.
+func (t *counterTracker) observeUptime(uptime float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if uptime < t.uptime {
+		// The Bbox rebooted, its counters restarted from zero.
+		t.counters = map[string]*trackedCounter{}
+		t.reboots++
+	}
+	t.uptime = uptime
+}
+
+// extend returns the monotonic value of the counter name with the given labels.
+func (t *counterTracker) extend(name string, raw float64, labels ...string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := name + "{" + strings.Join(labels, ",") + "}"
+	counter, ok := t.counters[key]
+	if !ok {
+		t.counters[key] = &trackedCounter{last: raw}
+		return raw
+	}
+	if raw < counter.last {
+		if counter.last < counterWrap && counterWrap-counter.last+raw <= maxWrapDistance {
+			counter.offset += counterWrap
+			t.wraps[name]++
+		} else {
+			// The counter restarted from zero: the exported counter resets too.
+			counter.offset = 0
+		}
+	}
+	counter.last = raw
+	return counter.offset + raw
+}
+
+// wrapsCount returns the number of wraps seen per counter name.
+func (t *counterTracker) wrapsCount() map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	wraps := make(map[string]float64, len(t.wraps))
+	for name, count := range t.wraps {
+		wraps[name] = count
+	}
+	return wraps
+}
+
+// rebootsCount returns the number of reboots seen since the exporter started.
+func (t *counterTracker) rebootsCount() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reboots
+}
@@ -0,0 +1,160 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+)
+
+func TestCounterTrackerExtend(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		want    []float64
+		wraps   float64
+	}{
+		{
+			name:    "increasing",
+			samples: []float64{10, 20, 30},
+			want:    []float64{10, 20, 30},
+		},
+		{
+			name:    "wrap",
+			samples: []float64{counterWrap - 100, 50},
+			want:    []float64{counterWrap - 100, counterWrap + 50},
+			wraps:   1,
+		},
+		{
+			name:    "two wraps",
+			samples: []float64{counterWrap - 100, 50, counterWrap - 10, 5},
+			want:    []float64{counterWrap - 100, counterWrap + 50, 2*counterWrap - 10, 2*counterWrap + 5},
+			wraps:   2,
+		},
+		{
+			name:    "reset of a small counter",
+			samples: []float64{1000000, 10},
+			want:    []float64{1000000, 10},
+		},
+		{
+			name:    "reset after a wrap",
+			samples: []float64{counterWrap - 100, 50, 10},
+			want:    []float64{counterWrap - 100, counterWrap + 50, 10},
+			wraps:   1,
+		},
+		{
+			name:    "reset of a 64-bit counter",
+			samples: []float64{counterWrap * 3, 10},
+			want:    []float64{counterWrap * 3, 10},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := newCounterTracker()
+			for i, sample := range test.samples {
+				if got := tracker.extend("bytes", sample); got != test.want[i] {
+					t.Errorf("sample %d: extend(%v) = %v, want %v", i, sample, got, test.want[i])
+				}
+			}
+			if got := tracker.wrapsCount()["bytes"]; got != test.wraps {
+				t.Errorf("wraps = %v, want %v", got, test.wraps)
+			}
+		})
+	}
+}
+
+func TestCounterTrackerReboot(t *testing.T) {
+	tracker := newCounterTracker()
+	tracker.observeUptime(1000)
+	tracker.extend("bytes", counterWrap-100)
+	tracker.observeUptime(10)
+	if got := tracker.extend("bytes", 50); got != 50 {
+		t.Errorf("extend after reboot = %v, want 50", got)
+	}
+	if got := tracker.wrapsCount()["bytes"]; got != 0 {
+		t.Errorf("wraps = %v, want 0", got)
+	}
+	if got := tracker.rebootsCount(); got != 1 {
+		t.Errorf("reboots = %v, want 1", got)
+	}
+}
+
+func TestCounterTrackerUptime(t *testing.T) {
+	tests := []struct {
+		name    string
+		uptimes []float64
+		reboots float64
+	}{
+		{
+			name:    "increasing",
+			uptimes: []float64{10, 70, 130},
+		},
+		{
+			name:    "same uptime",
+			uptimes: []float64{10, 10},
+		},
+		{
+			name:    "decrease",
+			uptimes: []float64{86400, 60},
+			reboots: 1,
+		},
+		{
+			name:    "two reboots",
+			uptimes: []float64{86400, 60, 120, 30},
+			reboots: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := newCounterTracker()
+			for _, uptime := range test.uptimes {
+				tracker.observeUptime(uptime)
+			}
+			if got := tracker.rebootsCount(); got != test.reboots {
+				t.Errorf("reboots = %v, want %v", got, test.reboots)
+			}
+		})
+	}
+}
+
+func TestRebootsDetected(t *testing.T) {
+	e := newTestExporter(t, Options{})
+	first := gatherSamples(t, e)
+	if got := first["bbox_reboots_detected_total"]; got != 0 {
+		t.Fatalf("bbox_reboots_detected_total = %v, want 0", got)
+	}
+
+	// The counters wrapped before a longer uptime: after the reboot, they
+	// restart from the values of the Bbox.
+	e.counters.mu.Lock()
+	e.counters.uptime = 10 * 86400
+	for _, counter := range e.counters.counters {
+		counter.offset = counterWrap
+	}
+	tracked := len(e.counters.counters)
+	e.counters.mu.Unlock()
+	if tracked == 0 {
+		t.Fatal("no counters tracked")
+	}
+
+	second := gatherSamples(t, e)
+	if got := second["bbox_reboots_detected_total"]; got != 1 {
+		t.Errorf("bbox_reboots_detected_total = %v, want 1", got)
+	}
+	for series, value := range first {
+		if second[series]-value >= counterWrap {
+			t.Errorf("%s = %v after the reboot, want %v", series, second[series], value)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sync"
+)
+
+// cpuTicksPerSecond is the clock tick of the Linux kernel of the Bbox
+// (USER_HZ), in which the CPU time is reported.
+const cpuTicksPerSecond = 100.0
+
+// cpuMeter computes the CPU utilisation from the CPU time of two
+// consecutive scrapes of the Bbox.
+type cpuMeter struct {
+	mu   sync.Mutex
+	last map[string]float64
+}
+
+func newCPUMeter() *cpuMeter {
+	return &cpuMeter{last: map[string]float64{}}
+}
+
+// observe records the CPU time of each mode, in ticks, with the total time
+// under "total". It returns the share of the CPU time spent in each mode
+// since the previous sample, computed under the same lock so concurrent
+// scrapes don't mix their samples.
+func (m *cpuMeter) observe(ticks map[string]float64) map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous := m.last
+	m.last = ticks
+	ratios := map[string]float64{}
+	elapsed := ticks["total"] - previous["total"]
+	if len(previous) == 0 || elapsed <= 0 {
+		// First sample or reboot: restart the measurement.
+		return ratios
+	}
+	for mode, value := range ticks {
+		if mode == "total" || value < previous[mode] {
+			continue
+		}
+		ratios[mode] = (value - previous[mode]) / elapsed
+	}
+	return ratios
+}
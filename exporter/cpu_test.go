@@ -0,0 +1,130 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+func TestCPUMeter(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []map[string]float64
+		want    map[string]float64
+	}{
+		{
+			name:    "first sample",
+			samples: []map[string]float64{{"total": 1000, "user": 100, "idle": 900}},
+			want:    map[string]float64{},
+		},
+		{
+			name: "two samples",
+			samples: []map[string]float64{
+				{"total": 1000, "user": 100, "idle": 900},
+				{"total": 1200, "user": 150, "idle": 1050},
+			},
+			want: map[string]float64{"user": 0.25, "idle": 0.75},
+		},
+		{
+			name: "reboot",
+			samples: []map[string]float64{
+				{"total": 1000, "user": 100, "idle": 900},
+				{"total": 200, "user": 50, "idle": 150},
+			},
+			want: map[string]float64{},
+		},
+		{
+			name: "counter reset of a mode",
+			samples: []map[string]float64{
+				{"total": 1000, "user": 100, "idle": 900},
+				{"total": 1200, "user": 20, "idle": 1050},
+			},
+			want: map[string]float64{"idle": 0.75},
+		},
+		{
+			name: "after a reboot",
+			samples: []map[string]float64{
+				{"total": 1000, "user": 100, "idle": 900},
+				{"total": 200, "user": 50, "idle": 150},
+				{"total": 400, "user": 100, "idle": 300},
+			},
+			want: map[string]float64{"user": 0.25, "idle": 0.75},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			meter := newCPUMeter()
+			var got map[string]float64
+			for _, sample := range test.samples {
+				got = meter.observe(sample)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ratios = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// deviceMetrics returns the device metrics of a scrape with the given CPU
+// time and memory.
+func deviceMetrics(t *testing.T, user, idle, total int, memory string) bbox.DeviceMetrics {
+	var metrics bbox.DeviceMetrics
+	document := fmt.Sprintf(`{
+		"informations": [{"device": {"modelname": "Bbox Fast 5330b"}}],
+		"device": [{"device": {"mem": %s}}],
+		"cpu": [{"device": {"cpu": {"time": {"total": %d, "user": %d, "idle": %d}}}}]
+	}`, memory, total, user, idle)
+	if err := json.Unmarshal([]byte(document), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	return metrics
+}
+
+func TestStoreDeviceRatios(t *testing.T) {
+	e := &Exporter{cpu: newCPUMeter()}
+	store := func(metrics bbox.DeviceMetrics) map[string]float64 {
+		return storeSamples(t, func(ch chan<- prometheus.Metric) {
+			e.storeDeviceMetrics(ch, metrics)
+		})
+	}
+
+	first := store(deviceMetrics(t, 100, 900, 1000, `{"total": 1000, "free": 300, "cached": 200}`))
+	if got := first["bbox_device_memory_used_ratio"]; got != 0.5 {
+		t.Errorf("bbox_device_memory_used_ratio = %v, want 0.5", got)
+	}
+	if got := first["bbox_device_cpu_seconds_total{mode=\"user\"}"]; got != 1 {
+		t.Errorf("bbox_device_cpu_seconds_total{mode=\"user\"} = %v, want 1", got)
+	}
+	for series := range first {
+		if series == `bbox_device_cpu_utilisation_ratio{mode="user"}` {
+			t.Errorf("%s exported after the first scrape", series)
+		}
+	}
+
+	second := store(deviceMetrics(t, 150, 1050, 1200, `{"total": 0, "free": 0, "cached": 0}`))
+	if got := second[`bbox_device_cpu_utilisation_ratio{mode="user"}`]; got != 0.25 {
+		t.Errorf(`bbox_device_cpu_utilisation_ratio{mode="user"} = %v, want 0.25`, got)
+	}
+	if _, ok := second["bbox_device_memory_used_ratio"]; ok {
+		t.Error("bbox_device_memory_used_ratio exported without the total memory")
+	}
+}
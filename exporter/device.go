@@ -15,96 +15,151 @@
 package exporter
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/nlamirault/bbox_exporter/bbox"
 )
 
 var (
-	deviceModelName = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "device_model_name"),
-		"Device model name",
-		[]string{"model_name"}, nil,
-	)
-	deviceUsing = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "device_fai_usage"),
-		"FAI box usage",
-		[]string{"using"}, nil,
-	)
-	deviceStatus = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "device_status"),
-		"Current status",
-		nil, nil,
-	)
-	deviceNumberOfBoots = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "device_number_of_boots"),
-		"Number of boots since last reset to factory default",
-		nil, nil,
-	)
-	deviceUptime = prometheus.NewDesc(
-                prometheus.BuildFQName(namespace, "", "device_uptime"),
-                "Uptime in seconds",
-                nil, nil,
-        )
-	deviceTemperature = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "device_temperature"),
-		"Current internal temperature in °C",
-		nil, nil,
-	)
+	deviceModelName     = newGauge("device_model_name", "Device model name", []string{"model_name"})
+	deviceInfo          = newGauge("device_info", "Model and firmware of the device", []string{"model", "firmware", "main_firmware", "backup_firmware", "serial_hash", "bootloader"})
+	deviceCapability    = newGauge("device_capability", "1 if the device supports the feature", []string{"capability"})
+	deviceUsing         = newGauge("device_fai_usage", "FAI box usage", []string{"using"})
+	deviceStatus        = newGauge("device_status", "Current status", nil)
+	deviceNumberOfBoots = newGauge("device_number_of_boots", "Number of boots since last reset to factory default", nil)
+	deviceUptime        = newGauge("device_uptime", "Uptime in seconds", nil)
+	deviceTemperature   = newGauge("device_temperature", "Current internal temperature in °C", nil)
+	deviceTempStatus    = newGauge("device_temperature_status", "Status of the internal temperature reported by the device", []string{"status"})
+	deviceConfigured    = newGauge("device_user_configured", "Has the device been configured by the user", nil)
+	deviceLuminosity    = newGauge("device_display_luminosity", "Luminosity of the display in percent", nil)
+	deviceLED           = newGauge("device_led_on", "Is the LED on", []string{"led"})
 
-	deviceMemory = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "device_memory"),
-		"Memory in kB",
-		[]string{"type"}, nil,
-	)
+	deviceMemory     = newGauge("device_memory", "Memory in kB", []string{"type"})
+	deviceMemoryUsed = newGauge("device_memory_used_ratio", "Memory used, without the cache, relative to the total memory", nil)
 
-	deviceCPU = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "device_cpu"),
-		"CPU Total Time",
-		[]string{"mode"}, nil,
-	)
+	deviceCPU            = newRenamedCounter("device_cpu", "CPU time in ticks", []string{"mode"})
+	deviceCPUSeconds     = newCounter("device_cpu_seconds_total", "CPU time in seconds", []string{"mode"})
+	deviceCPUUtilisation = newGauge("device_cpu_utilisation_ratio", "Share of the CPU time spent in the mode since the previous scrape", []string{"mode"})
 
-	deviceProcess = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "device_process"),
-		"Device process",
-		[]string{"type"}, nil,
-	)
+	deviceProcess = newGauge("device_process", "Device process", []string{"type"})
 )
 
-func describeDeviceMetrics(ch chan<- *prometheus.Desc) {
-	ch <- deviceModelName
-	ch <- deviceUsing
-	ch <- deviceStatus
-	ch <- deviceNumberOfBoots
-	ch <- deviceUptime
-	ch <- deviceTemperature
-	ch <- deviceMemory
-	ch <- deviceCPU
-	ch <- deviceProcess
+func (e *Exporter) describeDeviceMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, deviceModelName)
+	e.describeMetric(ch, deviceInfo)
+	e.describeMetric(ch, deviceCapability)
+	e.describeMetric(ch, deviceUsing)
+	e.describeMetric(ch, deviceStatus)
+	e.describeMetric(ch, deviceNumberOfBoots)
+	e.describeMetric(ch, deviceUptime)
+	e.describeMetric(ch, deviceTemperature)
+	e.describeMetric(ch, deviceTempStatus)
+	e.describeMetric(ch, deviceConfigured)
+	e.describeMetric(ch, deviceLuminosity)
+	e.describeMetric(ch, deviceLED)
+	e.describeMetric(ch, deviceMemory)
+	e.describeMetric(ch, deviceCPU)
+	e.describeMetric(ch, deviceCPUSeconds)
+	e.describeMetric(ch, deviceCPUUtilisation)
+	e.describeMetric(ch, deviceMemoryUsed)
+	e.describeMetric(ch, deviceProcess)
+}
+
+func (e *Exporter) storeDeviceMetrics(ch chan<- prometheus.Metric, metrics bbox.DeviceMetrics) {
+	e.storeMetric(ch, 1.0, deviceModelName, metrics.Informations[0].Device.ModelName)
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.Using.IPv4), deviceUsing, "ipv4")
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.Using.IPv6), deviceUsing, "ipv6")
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.Using.FTTH), deviceUsing, "ftth")
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.Using.ADSL), deviceUsing, "adsl")
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.Using.VDSL), deviceUsing, "vdsl")
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.Status), deviceStatus)
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.NumberOfBoots), deviceNumberOfBoots)
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.Uptime), deviceUptime)
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.Temperature.Current), deviceTemperature)
+	if status := metrics.Informations[0].Device.Temperature.Status; status != "" {
+		e.storeMetric(ch, 1.0, deviceTempStatus, status)
+	}
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.UserConfigured), deviceConfigured)
+	e.storeMetric(ch, float64(metrics.Informations[0].Device.Display.Luminosity), deviceLuminosity)
+	if len(metrics.LED) > 0 {
+		for led, state := range metrics.LED[0].LED {
+			e.storeMetric(ch, boolValue(state != 0), deviceLED, led)
+		}
+	}
+	e.storeMetric(ch, float64(metrics.Memory[0].Device.Memory.Total), deviceMemory, "total")
+	e.storeMetric(ch, float64(metrics.Memory[0].Device.Memory.Free), deviceMemory, "free")
+	e.storeMetric(ch, float64(metrics.Memory[0].Device.Memory.Cached), deviceMemory, "cached")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.Total), deviceCPU, "total")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.User), deviceCPU, "user")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.Nice), deviceCPU, "nice")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.System), deviceCPU, "system")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.IO), deviceCPU, "io")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.Idle), deviceCPU, "idle")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.Irq), deviceCPU, "irq")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Process.Created), deviceProcess, "created")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Process.Running), deviceProcess, "running")
+	e.storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Process.Blocked), deviceProcess, "blocked")
+	e.storeCPUUsage(ch, metrics.CPU[0])
+	memory := metrics.Memory[0].Device.Memory
+	if memory.Total > 0 {
+		used := float64(memory.Total - memory.Free - memory.Cached)
+		e.storeMetric(ch, used/float64(memory.Total), deviceMemoryUsed)
+	}
+}
+
+// storeCPUUsage converts the CPU time from ticks to seconds, and computes
+// the utilisation since the previous scrape.
+func (e *Exporter) storeCPUUsage(ch chan<- prometheus.Metric, cpu bbox.DeviceCPU) {
+	times := cpu.Device.CPU.Time
+	ticks := map[string]float64{
+		"total":  float64(times.Total),
+		"user":   float64(times.User),
+		"nice":   float64(times.Nice),
+		"system": float64(times.System),
+		"io":     float64(times.IO),
+		"idle":   float64(times.Idle),
+		"irq":    float64(times.Irq),
+	}
+	for mode, value := range ticks {
+		if mode != "total" {
+			e.storeMetric(ch, value/cpuTicksPerSecond, deviceCPUSeconds, mode)
+		}
+	}
+	for mode, ratio := range e.cpu.observe(ticks) {
+		e.storeMetric(ch, ratio, deviceCPUUtilisation, mode)
+	}
+}
+
+func (e *Exporter) storeDeviceInfo(ch chan<- prometheus.Metric, metrics bbox.DeviceMetrics, caps *bbox.Capabilities) {
+	device := metrics.Informations[0].Device
+	e.storeMetric(ch, 1.0, deviceInfo, device.ModelName, device.Running.Version, device.Main.Version, device.Reco.Version, serialHash(device.SerialNumber), device.Ldr1.Version)
+	if caps == nil {
+		return
+	}
+	capabilities := map[string]bool{
+		"xdsl":       caps.XDsl,
+		"ftth":       caps.Ftth,
+		"radio_6ghz": caps.Radio6GHz,
+		"usb":        caps.USB,
+		"iptv":       caps.IPTV,
+	}
+	for capability, supported := range capabilities {
+		value := 0.0
+		if supported {
+			value = 1.0
+		}
+		e.storeMetric(ch, value, deviceCapability, capability)
+	}
 }
 
-func storeDeviceMetrics(ch chan<- prometheus.Metric, metrics bbox.DeviceMetrics) {
-	storeMetric(ch, 1.0, deviceModelName, metrics.Informations[0].Device.ModelName)
-	storeMetric(ch, float64(metrics.Informations[0].Device.Using.IPv4), deviceUsing, "ipv4")
-	storeMetric(ch, float64(metrics.Informations[0].Device.Using.IPv6), deviceUsing, "ipv6")
-	storeMetric(ch, float64(metrics.Informations[0].Device.Using.FTTH), deviceUsing, "ftth")
-	storeMetric(ch, float64(metrics.Informations[0].Device.Using.ADSL), deviceUsing, "adsl")
-	storeMetric(ch, float64(metrics.Informations[0].Device.Using.VDSL), deviceUsing, "vdsl")
-	storeMetric(ch, metrics.Informations[0].Device.Status, deviceStatus)
-	storeMetric(ch, metrics.Informations[0].Device.NumberOfBoots, deviceNumberOfBoots)
-	storeMetric(ch, float64(metrics.Informations[0].Device.Uptime), deviceUptime)
-	storeMetric(ch, metrics.Informations[0].Device.Temperature.Current, deviceTemperature)
-	storeMetric(ch, metrics.Memory[0].Device.Memory.Total, deviceMemory, "total")
-	storeMetric(ch, metrics.Memory[0].Device.Memory.Free, deviceMemory, "free")
-	storeMetric(ch, metrics.Memory[0].Device.Memory.Cached, deviceMemory, "cached")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.Total), deviceCPU, "total")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.User), deviceCPU, "user")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.Nice), deviceCPU, "nice")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.System), deviceCPU, "system")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.IO), deviceCPU, "io")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.Idle), deviceCPU, "idle")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Time.Irq), deviceCPU, "irq")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Process.Created), deviceProcess, "created")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Process.Running), deviceProcess, "running")
-	storeMetric(ch, float64(metrics.CPU[0].Device.CPU.Process.Blocked), deviceProcess, "blocked")
+// serialHash identifies the device without exposing its serial number.
+func serialHash(serial string) string {
+	if serial == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(serial))
+	return hex.EncodeToString(sum[:])[:12]
 }
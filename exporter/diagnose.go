@@ -0,0 +1,137 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+// diagnoseTimeout bounds the wait for the results of the tests.
+const diagnoseTimeout = time.Minute
+
+// diagnoseResponse is the JSON document returned by DiagnoseHandler.
+type diagnoseResponse struct {
+	Target  string                    `json:"target"`
+	Results []bbox.WanDiagsStatistics `json:"results"`
+	Error   string                    `json:"error,omitempty"`
+}
+
+// diagnosticsCollector exports the results of a single diagnostics run.
+type diagnosticsCollector struct {
+	exporter *Exporter
+	results  []bbox.WanDiagsStatistics
+}
+
+func (c *diagnosticsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.exporter.describeWanDiagnosticsMetrics(ch)
+}
+
+func (c *diagnosticsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.exporter.storeWanDiagnosticsMetrics(ch, c.results)
+}
+
+// DiagnoseHandler runs the WAN diagnostics of the Bbox and returns their
+// results as JSON. The target parameter keeps only one test: dns, ping or http.
+func (e *Exporter) DiagnoseHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		results, status, err := e.diagnose(target)
+		response := diagnoseResponse{
+			Target:  target,
+			Results: results,
+		}
+		if err != nil {
+			response.Error = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			level.Error(e.logger).Log("msg", "Can't encode diagnostics", "err", err)
+		}
+	})
+}
+
+// DiagnoseMetricsHandler runs the WAN diagnostics of the Bbox and returns
+// their results as a one-shot metrics page.
+func (e *Exporter) DiagnoseMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results, status, err := e.diagnose(r.URL.Query().Get("target"))
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(&diagnosticsCollector{exporter: e, results: results})
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// diagnose runs the WAN diagnostics and returns the results of the target,
+// with the HTTP status of the response. A single run is allowed at a time.
+func (e *Exporter) diagnose(target string) ([]bbox.WanDiagsStatistics, int, error) {
+	switch target {
+	case "", "dns", "ping", "http":
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid target %q: must be dns, ping or http", target)
+	}
+
+	if !atomic.CompareAndSwapInt32(&e.diagnosing, 0, 1) {
+		return nil, http.StatusConflict, fmt.Errorf("WAN diagnostics already running")
+	}
+	defer atomic.StoreInt32(&e.diagnosing, 0)
+	level.Info(e.logger).Log("msg", "Run WAN diagnostics", "target", target)
+	if err := e.Bbox.Authenticate(); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	results, err := e.Bbox.RunWANDiagnostics(diagnoseTimeout)
+	if errors.Is(err, bbox.ErrNotSupported) {
+		return nil, http.StatusNotImplemented, err
+	}
+	if err != nil {
+		return filterDiagnostics(results, target), http.StatusGatewayTimeout, err
+	}
+	return filterDiagnostics(results, target), http.StatusOK, nil
+}
+
+// filterDiagnostics keeps only the tests of the target. An empty target
+// keeps all of them.
+func filterDiagnostics(results []bbox.WanDiagsStatistics, target string) []bbox.WanDiagsStatistics {
+	if target == "" {
+		return results
+	}
+	filtered := make([]bbox.WanDiagsStatistics, len(results))
+	for i, result := range results {
+		switch target {
+		case "dns":
+			filtered[i].Diags.DNS = result.Diags.DNS
+		case "ping":
+			filtered[i].Diags.Ping = result.Diags.Ping
+		case "http":
+			filtered[i].Diags.HTTP = result.Diags.HTTP
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-kit/log"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+// loadDiagnostics returns the WAN diagnostics of the fixtures.
+func loadDiagnostics(t *testing.T) []bbox.WanDiagsStatistics {
+	content, err := ioutil.ReadFile(filepath.Join(fixturesDir, "wan_diags.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []bbox.WanDiagsStatistics
+	if err := json.Unmarshal(content, &results); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestFilterDiagnostics(t *testing.T) {
+	results := loadDiagnostics(t)
+	tests := []struct {
+		target string
+		dns    int
+		ping   int
+		http   int
+	}{
+		{target: "", dns: 3, ping: 2, http: 1},
+		{target: "dns", dns: 3},
+		{target: "ping", ping: 2},
+		{target: "http", http: 1},
+	}
+	for _, test := range tests {
+		filtered := filterDiagnostics(results, test.target)
+		if len(filtered) != len(results) {
+			t.Fatalf("target %q: %d results, want %d", test.target, len(filtered), len(results))
+		}
+		diags := filtered[0].Diags
+		if len(diags.DNS) != test.dns || len(diags.Ping) != test.ping || len(diags.HTTP) != test.http {
+			t.Errorf("target %q: %d dns, %d ping and %d http tests, want %d, %d and %d", test.target,
+				len(diags.DNS), len(diags.Ping), len(diags.HTTP), test.dns, test.ping, test.http)
+		}
+	}
+	// The results of the Bbox are not modified.
+	if diags := results[0].Diags; len(diags.DNS) != 3 || len(diags.Ping) != 2 || len(diags.HTTP) != 1 {
+		t.Errorf("results modified by the filter: %+v", diags)
+	}
+}
+
+func TestDiagnoseHandler(t *testing.T) {
+	transport := &countingTransport{}
+	e, err := NewExporter("https://bbox.test", "password", Options{Transport: transport}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := e.DiagnoseHandler()
+
+	diagnose := func(target string) (int, diagnoseResponse) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/diagnose?target="+target, nil))
+		var response diagnoseResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: %q", err, recorder.Body.String())
+		}
+		return recorder.Code, response
+	}
+
+	if status, response := diagnose("dhcp"); status != http.StatusBadRequest || response.Error == "" {
+		t.Errorf("invalid target = %d %+v, want %d with an error", status, response, http.StatusBadRequest)
+	}
+
+	// A run is in progress.
+	atomic.StoreInt32(&e.diagnosing, 1)
+	if status, response := diagnose("dns"); status != http.StatusConflict || response.Error == "" {
+		t.Errorf("concurrent run = %d %+v, want %d with an error", status, response, http.StatusConflict)
+	}
+	if n := transport.count(); n != 0 {
+		t.Errorf("%d requests sent to the Bbox during a run", n)
+	}
+	if atomic.LoadInt32(&e.diagnosing) != 1 {
+		t.Error("the run in progress was marked finished by the conflicting request")
+	}
+}
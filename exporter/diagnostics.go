@@ -0,0 +1,111 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	// The target tells apart the tests of the same mode and protocol, e.g.
+	// the DNS queries of several hosts.
+	diagnosticsLabels = []string{"mode", "protocol", "target", "status"}
+
+	diagnosticsMinWan          = newGauge("wan_diagnostics_min", "Minimum response Time", diagnosticsLabels)
+	diagnosticsMaxWan          = newGauge("wan_diagnostics_max", "Maximum response Time", diagnosticsLabels)
+	diagnosticsAvgWan          = newGauge("wan_diagnostics_avg", "Average response Time", diagnosticsLabels)
+	diagnosticsNumberOfSuccess = newGauge("wan_diagnostics_success", "Number of sucess", diagnosticsLabels)
+	diagnosticsNumberOfError   = newGauge("wan_diagnostics_error", "Number of error", diagnosticsLabels)
+	diagnosticsNumberOfTries   = newGauge("wan_diagnostics_tries", "Number of tries", diagnosticsLabels)
+	diagnosticsSuccessRatio    = newGauge("wan_diagnostics_success_ratio", "Ratio of successful tries", diagnosticsLabels)
+	diagnosticsStatus          = newGauge("wan_diagnostics_status", "Status of the test: 1 for success, 0 for error, -1 when not run", diagnosticsLabels)
+)
+
+func (e *Exporter) describeWanDiagnosticsMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, diagnosticsMinWan)
+	e.describeMetric(ch, diagnosticsMaxWan)
+	e.describeMetric(ch, diagnosticsAvgWan)
+	e.describeMetric(ch, diagnosticsNumberOfSuccess)
+	e.describeMetric(ch, diagnosticsNumberOfError)
+	e.describeMetric(ch, diagnosticsNumberOfTries)
+	e.describeMetric(ch, diagnosticsSuccessRatio)
+	e.describeMetric(ch, diagnosticsStatus)
+}
+
+func (e *Exporter) storeWanDiagnosticsMetrics(ch chan<- prometheus.Metric, metrics []bbox.WanDiagsStatistics) {
+	if len(metrics) == 0 {
+		level.Warn(e.logger).Log("msg", "No WAN diagnostics")
+		return
+	}
+	// A test may be listed twice: only the first one is kept, as duplicated
+	// series would fail the scrape.
+	seen := map[string]bool{}
+	for mode, diagnostics := range map[string][]bbox.WanDiagnostic{
+		"dns":  metrics[0].Diags.DNS,
+		"ping": metrics[0].Diags.Ping,
+		"http": metrics[0].Diags.HTTP,
+	} {
+		for _, diagnostic := range diagnostics {
+			key := mode + "/" + diagnostic.Protocol + "/" + diagnostic.Host
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			e.storeWanDiagnostic(ch, mode, diagnostic)
+		}
+	}
+}
+
+func (e *Exporter) storeWanDiagnostic(ch chan<- prometheus.Metric, mode string, diagnostic bbox.WanDiagnostic) {
+	labels := []string{mode, diagnostic.Protocol, diagnostic.Host, diagnosticStatusLabel(diagnostic.Status)}
+	e.storeMetric(ch, diagnosticStatus(diagnostic.Status), diagnosticsStatus, labels...)
+	e.storeMetric(ch, float64(diagnostic.Success), diagnosticsNumberOfSuccess, labels...)
+	e.storeMetric(ch, float64(diagnostic.Error), diagnosticsNumberOfError, labels...)
+	e.storeMetric(ch, float64(diagnostic.Tries), diagnosticsNumberOfTries, labels...)
+	if diagnostic.Tries == 0 {
+		// Not run yet: response times are meaningless.
+		return
+	}
+	e.storeMetric(ch, float64(diagnostic.Min), diagnosticsMinWan, labels...)
+	e.storeMetric(ch, float64(diagnostic.Max), diagnosticsMaxWan, labels...)
+	e.storeMetric(ch, float64(diagnostic.Average), diagnosticsAvgWan, labels...)
+	e.storeMetric(ch, float64(diagnostic.Success)/float64(diagnostic.Tries), diagnosticsSuccessRatio, labels...)
+}
+
+// diagnosticStatusLabel returns the status label of a test, e.g. success,
+// error or idle.
+func diagnosticStatusLabel(status string) string {
+	if status == "" {
+		return "unknown"
+	}
+	return strings.ToLower(status)
+}
+
+// diagnosticStatus returns the value of the status of a test.
+func diagnosticStatus(status string) float64 {
+	switch strings.ToLower(status) {
+	case "success":
+		return 1
+	case "error":
+		return 0
+	default:
+		return -1
+	}
+}
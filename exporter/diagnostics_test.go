@@ -0,0 +1,40 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+)
+
+func TestStoreWanDiagnosticsMetrics(t *testing.T) {
+	samples := gatherSamples(t, newTestExporter(t, Options{}))
+	for series, want := range map[string]float64{
+		`bbox_wan_diagnostics_status{mode="dns",protocol="IPv4",status="success",target="host-062c4c"}`:        1,
+		`bbox_wan_diagnostics_status{mode="dns",protocol="IPv6",status="error",target="host-062c4c"}`:          0,
+		`bbox_wan_diagnostics_status{mode="dns",protocol="IPv4",status="success",target="host-92faaf"}`:        1,
+		`bbox_wan_diagnostics_status{mode="ping",protocol="IPv6",status="idle",target="198.19.152.106"}`:       -1,
+		`bbox_wan_diagnostics_tries{mode="http",protocol="IPv4",status="success",target="host-25bbad"}`:        3,
+		`bbox_wan_diagnostics_tries{mode="ping",protocol="IPv6",status="idle",target="198.19.152.106"}`:        0,
+		`bbox_wan_diagnostics_success_ratio{mode="dns",protocol="IPv4",status="success",target="host-062c4c"}`: 1,
+	} {
+		if got, ok := samples[series]; !ok || got != want {
+			t.Errorf("%s = %v, want %v", series, got, want)
+		}
+	}
+	// A test not run has no response times nor success ratio.
+	if _, ok := samples[`bbox_wan_diagnostics_success_ratio{mode="ping",protocol="IPv6",status="idle",target="198.19.152.106"}`]; ok {
+		t.Error("success ratio exported for a test not run")
+	}
+}
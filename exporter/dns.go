@@ -15,44 +15,81 @@
 package exporter
 
 import (
+	"fmt"
+
+	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/nlamirault/bbox_exporter/bbox"
 )
 
 var (
-	dnsNumberOfQueries = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "dns_number_of_queries"),
-		"Number of queries",
-		nil, nil,
-	)
-	dnsMin = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "dns_min"),
-		"Minimun of average dns response time",
-		nil, nil,
-	)
-	dnsMax = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "dns_max"),
-		"Maximun of average dns response time",
-		nil, nil,
-	)
-	dnsAverage = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "dns_average"),
-		"Average of average dns response time",
-		nil, nil,
-	)
+	dnsNumberOfQueries = newCounter("dns_number_of_queries_total", "Number of queries", []string{"server"})
+	dnsMin             = newGauge("dns_min", "Minimun of average dns response time", []string{"server"})
+	dnsMax             = newGauge("dns_max", "Maximun of average dns response time", []string{"server"})
+	dnsAverage         = newGauge("dns_average", "Average of average dns response time", []string{"server"})
+	dnsFailures        = newCounter("dns_failed_queries_total", "Number of failed queries", []string{"server"})
+	dnsCacheHits       = newCounter("dns_cache_hits_total", "Number of queries answered from the cache", []string{"server"})
+	dnsCacheMisses     = newCounter("dns_cache_misses_total", "Number of queries not found in the cache", []string{"server"})
+
+	// dnsLegacyNumberOfQueries is the gauge exported before the resolvers were
+	// labelled, summed over the resolvers.
+	dnsLegacyNumberOfQueries = newGauge("dns_number_of_queries", "Number of queries (deprecated)", nil)
 )
 
-func describeDNSMetrics(ch chan<- *prometheus.Desc) {
-	ch <- dnsNumberOfQueries
-	ch <- dnsMin
-	ch <- dnsMax
-	ch <- dnsAverage
+func (e *Exporter) describeDNSMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, dnsNumberOfQueries)
+	if e.options.CompatGaugeNames {
+		e.describeMetric(ch, dnsLegacyNumberOfQueries)
+	}
+	e.describeMetric(ch, dnsMin)
+	e.describeMetric(ch, dnsMax)
+	e.describeMetric(ch, dnsAverage)
+	e.describeMetric(ch, dnsFailures)
+	e.describeMetric(ch, dnsCacheHits)
+	e.describeMetric(ch, dnsCacheMisses)
+}
+
+func (e *Exporter) storeDNSMetrics(ch chan<- prometheus.Metric, metrics bbox.DNSMetrics) {
+	seen := map[string]bool{}
+	queries := 0.0
+	for i, stats := range metrics.Servers {
+		server := dnsServerName(i, stats.DNS.Server)
+		if seen[server] {
+			level.Warn(e.logger).Log("msg", "Duplicated DNS server", "server", server)
+			continue
+		}
+		seen[server] = true
+
+		queries += float64(stats.DNS.NumberOfQueries)
+		e.storeMetric(ch, float64(stats.DNS.NumberOfQueries), dnsNumberOfQueries, server)
+		e.storeMetric(ch, float64(stats.DNS.Min), dnsMin, server)
+		e.storeMetric(ch, float64(stats.DNS.Max), dnsMax, server)
+		e.storeMetric(ch, float64(stats.DNS.Average), dnsAverage, server)
+		if stats.DNS.Failures != nil {
+			e.storeMetric(ch, float64(*stats.DNS.Failures), dnsFailures, server)
+		}
+		if stats.DNS.CacheHits != nil {
+			e.storeMetric(ch, float64(*stats.DNS.CacheHits), dnsCacheHits, server)
+		}
+		if stats.DNS.CacheMisses != nil {
+			e.storeMetric(ch, float64(*stats.DNS.CacheMisses), dnsCacheMisses, server)
+		}
+	}
+	if e.options.CompatGaugeNames && len(seen) > 0 {
+		e.storeMetric(ch, queries, dnsLegacyNumberOfQueries)
+	}
 }
 
-func storeDNSMetrics(ch chan<- prometheus.Metric, metrics bbox.DNSMetrics) {
-	storeMetric(ch, metrics.Principal[0].DNS.NumberOfQueries, dnsNumberOfQueries)
-	storeMetric(ch, metrics.Principal[0].DNS.Min, dnsMin)
-	storeMetric(ch, metrics.Principal[0].DNS.Max, dnsMax)
-	storeMetric(ch, metrics.Principal[0].DNS.Average, dnsAverage)
+// dnsServerName returns the label of a resolver. Firmwares which don't name
+// the resolvers list the principal one first.
+func dnsServerName(index int, server string) string {
+	switch {
+	case server != "":
+		return server
+	case index == 0:
+		return "principal"
+	default:
+		return fmt.Sprintf("resolver%d", index)
+	}
 }
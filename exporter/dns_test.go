@@ -0,0 +1,39 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+)
+
+func TestStoreDNSMetrics(t *testing.T) {
+	samples := gatherSamples(t, newTestExporter(t, Options{CompatGaugeNames: true}))
+	for series, want := range map[string]float64{
+		`bbox_dns_number_of_queries_total{server="principal"}`:      1500,
+		`bbox_dns_number_of_queries_total{server="198.19.149.147"}`: 30,
+		`bbox_dns_min{server="principal"}`:                          5,
+		// The legacy gauge has no server label.
+		`bbox_dns_number_of_queries`: 1530,
+	} {
+		if got, ok := samples[series]; !ok || got != want {
+			t.Errorf("%s = %v, want %v", series, got, want)
+		}
+	}
+
+	samples = gatherSamples(t, newTestExporter(t, Options{}))
+	if _, ok := samples["bbox_dns_number_of_queries"]; ok {
+		t.Error("bbox_dns_number_of_queries exported without --compat.gauge-names")
+	}
+}
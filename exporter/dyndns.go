@@ -0,0 +1,116 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"net"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	dyndnsEnabled    = newGauge("dyndns_enabled", "Is the dynamic DNS provider enabled", []string{"provider", "hostname"})
+	dyndnsLastResult = newGauge("dyndns_last_update_result", "Result of the last update sent to the provider", []string{"provider", "hostname", "result"})
+	dyndnsLastUpdate = newGauge("dyndns_last_update_timestamp_seconds", "Date of the last update sent to the provider", []string{"provider", "hostname"})
+	dyndnsIP         = newGauge("dyndns_registered_ip_info", "IP address registered by the provider", []string{"provider", "hostname", "ip"})
+	dyndnsInSync     = newGauge("dyndns_in_sync", "Is the registered IP address a WAN address of its record type", []string{"provider", "hostname"})
+)
+
+func (e *Exporter) describeDynDNSMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, dyndnsEnabled)
+	e.describeMetric(ch, dyndnsLastResult)
+	e.describeMetric(ch, dyndnsLastUpdate)
+	e.describeMetric(ch, dyndnsIP)
+	e.describeMetric(ch, dyndnsInSync)
+}
+
+func (e *Exporter) storeDynDNSMetrics(ch chan<- prometheus.Metric, metrics bbox.DynDNSMetrics, wan bbox.WanMetrics) {
+	if len(metrics.Informations) == 0 {
+		return
+	}
+	wanIPs := map[string][]string{}
+	if len(wan.IPInformations) > 0 {
+		ip := wan.IPInformations[0].Wan.IP
+		if ip.Address != "" {
+			wanIPs["A"] = []string{ip.Address}
+		}
+		wanIPs["AAAA"] = ipv6Addresses(ip.IP6Address)
+	}
+	seen := map[string]bool{}
+	for _, domain := range metrics.Informations[0].Dyndns.Domain {
+		key := domain.Server + "/" + domain.Host
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		e.storeMetric(ch, boolValue(bool(domain.Enable)), dyndnsEnabled, domain.Server, domain.Host)
+		if domain.Status.Status != "" {
+			e.storeMetric(ch, 1.0, dyndnsLastResult, domain.Server, domain.Host, strings.ToLower(domain.Status.Status))
+		}
+		if lastUpdate, err := domain.LastUpdate(); err == nil {
+			e.storeMetric(ch, float64(lastUpdate.Unix()), dyndnsLastUpdate, domain.Server, domain.Host)
+		}
+		if domain.Status.IP == "" {
+			continue
+		}
+		e.storeMetric(ch, 1.0, dyndnsIP, domain.Server, domain.Host, domain.Status.IP)
+		// The registered address is compared with the WAN addresses of its
+		// record type. Older firmwares don't tell the record, which is A.
+		record := strings.ToUpper(domain.Record)
+		if record == "" {
+			record = "A"
+		}
+		addresses := wanIPs[record]
+		if len(addresses) == 0 {
+			continue
+		}
+		inSync := 0.0
+		if containsIP(addresses, domain.Status.IP) {
+			inSync = 1.0
+		}
+		e.storeMetric(ch, inSync, dyndnsInSync, domain.Server, domain.Host)
+	}
+}
+
+// ipv6Addresses returns the IPv6 addresses of the WAN. The Bbox lists them
+// as strings or as objects with an ipaddress field, depending on the firmware.
+func ipv6Addresses(entries []interface{}) []string {
+	var addresses []string
+	for _, entry := range entries {
+		switch entry := entry.(type) {
+		case string:
+			addresses = append(addresses, entry)
+		case map[string]interface{}:
+			if address, ok := entry["ipaddress"].(string); ok {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
+
+// containsIP returns true if ip is one of the addresses, whatever their notation.
+func containsIP(addresses []string, ip string) bool {
+	parsed := net.ParseIP(ip)
+	for _, address := range addresses {
+		if address == ip || (parsed != nil && parsed.Equal(net.ParseIP(address))) {
+			return true
+		}
+	}
+	return false
+}
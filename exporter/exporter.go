@@ -15,6 +15,12 @@
 package exporter
 
 import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
 	"github.com/go-kit/kit/log/level"
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -27,84 +33,307 @@ const (
 )
 
 var (
-	up = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "up"),
-		"Was the last query of BBox successful.",
-		nil, nil,
-	)
+	up           = newGauge("up", "Was the last query of BBox successful.", nil)
+	counterWraps = newCounter("counter_wraps_total", "Number of 32-bit wraps of the Bbox counters seen by the exporter", []string{"metric"})
+	reboots      = newCounter("reboots_detected_total", "Number of reboots of the Bbox detected from its uptime", nil)
+	decodeErrors = newCounter("decode_errors_total", "Number of values of the Bbox API skipped because they could not be decoded", []string{"endpoint", "field"})
 )
 
+// metric is a Prometheus descriptor together with the type of the value
+// exported for it.
+type metric struct {
+	name      string
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	// legacy is the gauge exported for a counter before it was renamed,
+	// kept while dashboards are migrated.
+	legacy *prometheus.Desc
+}
+
+func newMetric(name string, help string, labels []string, valueType prometheus.ValueType) metric {
+	fqName := prometheus.BuildFQName(namespace, "", name)
+	return metric{
+		name:      fqName,
+		desc:      prometheus.NewDesc(fqName, help, labels, nil),
+		valueType: valueType,
+	}
+}
+
+func newGauge(name string, help string, labels []string) metric {
+	return newMetric(name, help, labels, prometheus.GaugeValue)
+}
+
+func newCounter(name string, help string, labels []string) metric {
+	return newMetric(name, help, labels, prometheus.CounterValue)
+}
+
+// newRenamedCounter returns a counter named with a _total suffix. The name
+// without the suffix is the gauge exported by previous versions of the exporter.
+func newRenamedCounter(name string, help string, labels []string) metric {
+	m := newCounter(name+"_total", help, labels)
+	m.legacy = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help+" (deprecated)", labels, nil)
+	return m
+}
+
+// Options holds the optional behaviours of the Exporter.
+type Options struct {
+	// CompatGaugeNames also exports counters as gauges under their
+	// pre-counter names.
+	CompatGaugeNames bool
+	// ThroughputInterval polls the WAN statistics in the background to
+	// compute the throughput. When zero, it is computed between scrapes.
+	ThroughputInterval time.Duration
+	// EnableWOL counts the Wake-on-LAN requests sent through the exporter.
+	EnableWOL bool
+	// EnableReboot counts the reboots triggered through the exporter.
+	EnableReboot bool
+	// ActionInterval is the minimum interval between two runs of an action
+	// on the same target.
+	ActionInterval time.Duration
+	// AuditLog receives the actions triggered through the exporter, as JSON
+	// lines. The actions are always logged.
+	AuditLog io.Writer
+	// Transport replaces the transport of the requests to the Bbox, to
+	// record or replay its responses.
+	Transport http.RoundTripper
+	// Lite builds the core metrics from the /summary endpoint only.
+	Lite bool
+	// MQTT publishes the state of the Bbox to an MQTT broker.
+	MQTT MQTTOptions
+	// KnownHosts are the MAC addresses of the hosts expected on the network.
+	// Without them, the hosts of the first scrape are known.
+	KnownHosts []string
+	// PresenceWebhook receives the new hosts seen on the network.
+	PresenceWebhook string
+	// HostExpiry is the time after which a host not seen active is
+	// forgotten. Zero keeps the hosts forever.
+	HostExpiry time.Duration
+	// SnapshotMaxAge is the maximum age of the last scrape rendered by the
+	// InfluxDB and JSON handlers. An older scrape, or none, triggers a new
+	// one. When zero, each request scrapes the Bbox.
+	SnapshotMaxAge time.Duration
+}
+
 // Exporter collects Bbox stats from the given server and exports them using
 // the prometheus metrics package.
 type Exporter struct {
-	Bbox   *bbox.Client
-	logger log.Logger
+	Bbox       *bbox.Client
+	options    Options
+	counters   *counterTracker
+	throughput *throughputMeter
+	cpu        *cpuMeter
+	// diagnosing is 1 while the WAN diagnostics run.
+	diagnosing int32
+	actions    *actionRecorder
+	presence   *presenceTracker
+	webhook    *requestCounter
+	// webhookEvents are the events waiting to be posted to the webhook.
+	webhookEvents chan presenceEvent
+	snapshot      *snapshotStore
+	logger        log.Logger
 }
 
 // NewExporter returns an initialized Exporter.
-func NewExporter(endpoint string, password string, logger log.Logger) (*Exporter, error) {
+func NewExporter(endpoint string, password string, options Options, logger log.Logger) (*Exporter, error) {
 	level.Info(logger).Log("msg", "Setup BBox exporter")
+	// The options are validated before any goroutine is started.
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
 	bboxClient, err := bbox.NewClient(endpoint, password, logger)
 	if err != nil {
 		return nil, err
 	}
-	return &Exporter{
-		Bbox:   bboxClient,
-		logger: logger,
-	}, nil
+	if options.Transport != nil {
+		bboxClient.SetTransport(options.Transport)
+	}
+	exporter := &Exporter{
+		Bbox:       bboxClient,
+		options:    options,
+		counters:   newCounterTracker(),
+		throughput: newThroughputMeter(),
+		cpu:        newCPUMeter(),
+		actions:    newActionRecorder(options.ActionInterval, options.AuditLog),
+		presence:   newPresenceTracker(options.KnownHosts, options.HostExpiry),
+		webhook:    newRequestCounter("success", "error", "dropped"),
+		snapshot:   &snapshotStore{},
+		logger:     logger,
+	}
+	if options.ThroughputInterval > 0 {
+		go exporter.sampleThroughput(options.ThroughputInterval)
+	}
+	if options.PresenceWebhook != "" {
+		exporter.webhookEvents = make(chan presenceEvent, webhookQueueSize)
+		go exporter.runWebhook()
+	}
+	if options.MQTT.Broker != "" {
+		go newMQTTPublisher(exporter, options.MQTT).run()
+	}
+	return exporter, nil
+}
+
+// validateOptions checks the options of the Exporter.
+func validateOptions(options Options) error {
+	for name, interval := range map[string]time.Duration{
+		"throughput interval": options.ThroughputInterval,
+		"action interval":     options.ActionInterval,
+		"host expiry":         options.HostExpiry,
+		"snapshot max age":    options.SnapshotMaxAge,
+	} {
+		if interval < 0 {
+			return fmt.Errorf("invalid %s: %s", name, interval)
+		}
+	}
+	if options.PresenceWebhook != "" {
+		u, err := url.Parse(options.PresenceWebhook)
+		if err != nil {
+			return fmt.Errorf("invalid presence webhook: %s", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid presence webhook: unsupported scheme %q", u.Scheme)
+		}
+	}
+	if options.MQTT.Broker != "" {
+		if options.Lite {
+			// The state published is built from the full collection.
+			return fmt.Errorf("the MQTT publication is not available in lite mode")
+		}
+		return validateMQTTOptions(options.MQTT)
+	}
+	return nil
 }
 
 // Describe describes all the metrics ever exported by the Bbox exporter.
 // It implements prometheus.Collector.
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	ch <- up
-	describeWanMetrics(ch)
-	describeLanMetrics(ch)
-	describeDeviceMetrics(ch)
-	describeDNSMetrics(ch)
-	describeIPTVMetrics(ch)
-	describeServicesMetrics(ch)
-	describeWirelessMetrics(ch)
+	e.describeMetric(ch, up)
+	e.describeMetric(ch, counterWraps)
+	e.describeMetric(ch, reboots)
+	e.describeMetric(ch, decodeErrors)
+	e.describeWanMetrics(ch)
+	e.describeLanMetrics(ch)
+	e.describeDeviceMetrics(ch)
+	e.describeDNSMetrics(ch)
+	e.describeIPTVMetrics(ch)
+	e.describeServicesMetrics(ch)
+	e.describeWirelessMetrics(ch)
+	e.describeWirelessAccessMetrics(ch)
+	e.describeParentalControlMetrics(ch)
+	e.describeUSBMetrics(ch)
+	e.describeHotspotMetrics(ch)
+	e.describeDynDNSMetrics(ch)
+	e.describeActionsMetrics(ch)
+	e.describeSummaryMetrics(ch)
+	e.describePresenceMetrics(ch)
 }
 
 // Collect the stats from channel and delivers them as Prometheus metrics.
-// It implements prometheus.Collector.
+// It implements prometheus.Collector. The metrics are kept for the InfluxDB
+// and JSON handlers.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	var collected []prometheus.Metric
+	go func() {
+		for m := range metrics {
+			collected = append(collected, m)
+			ch <- m
+		}
+		close(done)
+	}()
+	response := e.collect(metrics)
+	close(metrics)
+	<-done
+	e.snapshot.store(collected, response, time.Now())
+}
+
+// collect returns the response of the Bbox, nil when the Bbox can't be
+// polled or in lite mode.
+func (e *Exporter) collect(ch chan<- prometheus.Metric) *bbox.Metrics {
 	level.Info(e.logger).Log("msg", "Bbox exporter starting")
+	e.storeActionsMetrics(ch)
+	defer e.storeDecodeErrors(ch)
 
 	if err := e.Bbox.Authenticate(); err != nil {
-		ch <- prometheus.MustNewConstMetric(
-			up, prometheus.GaugeValue, 0,
-		)
+		e.storeMetric(ch, 0, up)
 		level.Error(e.logger).Log("msg", "Bbox authentication error", "err", err.Error())
-		return
+		return nil
+	}
+
+	if e.options.Lite {
+		if err := e.collectSummary(ch); err != nil {
+			e.storeMetric(ch, 0, up)
+			level.Error(e.logger).Log("msg", "Bbox summary error", "err", err.Error())
+			return nil
+		}
+		e.storeMetric(ch, 1, up)
+		return nil
 	}
 
 	resp, err := e.Bbox.GetMetrics()
 	if err != nil {
-		ch <- prometheus.MustNewConstMetric(
-			up, prometheus.GaugeValue, 0,
-		)
+		e.storeMetric(ch, 0, up)
 		level.Error(e.logger).Log("msg", "Bbox API error", "err", err.Error())
-		return
+		return nil
 	}
 
 	level.Info(e.logger).Log("msg", "Bbox metrics retrieved")
-	storeServicesMetrics(ch, resp.Services)
-	storeDeviceMetrics(ch, resp.Device)
-	storeDNSMetrics(ch, resp.DNS)
-	storeLanMetrics(e.logger, ch, resp.Lan)
-	storeWanMetrics(ch, resp.Wan)
-	storeWanFtthMetric(ch, resp.FtthState)
-	storeWirelessMetrics(ch, resp.Wireless)
-	storeIPTVMetrics(ch, resp.IPTV)
-	ch <- prometheus.MustNewConstMetric(
-		up, prometheus.GaugeValue, 1,
-	)
+	if len(resp.Device.Informations) > 0 {
+		e.counters.observeUptime(float64(resp.Device.Informations[0].Device.Uptime))
+	}
+	e.storeServicesMetrics(ch, resp.Services)
+	e.storeDeviceMetrics(ch, resp.Device)
+	e.storeDeviceInfo(ch, resp.Device, resp.Capabilities)
+	e.storeDNSMetrics(ch, resp.DNS)
+	e.storeLanMetrics(ch, resp.Lan)
+	e.storePresenceMetrics(ch, resp.Lan)
+	e.storeWanMetrics(ch, resp.Wan)
+	e.storeWirelessMetrics(ch, resp.Wireless)
+	e.storeWirelessAccessMetrics(ch, resp.Wireless.AccessControl, deviceTime(resp.Device))
+	e.storeIPTVMetrics(ch, resp.IPTV)
+	e.storeParentalControlMetrics(ch, resp.ParentalControl, resp.Lan)
+	e.storeUSBMetrics(ch, resp.USB)
+	e.storeHotspotMetrics(ch, resp.Hotspot)
+	e.storeDynDNSMetrics(ch, resp.DynDNS, resp.Wan)
+	for name, count := range e.counters.wrapsCount() {
+		e.storeMetric(ch, count, counterWraps, name)
+	}
+	e.storeMetric(ch, e.counters.rebootsCount(), reboots)
+	e.storeMetric(ch, 1, up)
 	level.Info(e.logger).Log("msg", "Metrics collection finished")
+	return resp
+}
+
+// storeDecodeErrors stores the decode errors of the responses of the Bbox.
+func (e *Exporter) storeDecodeErrors(ch chan<- prometheus.Metric) {
+	for decodeError, count := range e.Bbox.DecodeErrors() {
+		e.storeMetric(ch, count, decodeErrors, decodeError.Endpoint, decodeError.Field)
+	}
+}
+
+func (e *Exporter) describeMetric(ch chan<- *prometheus.Desc, m metric) {
+	ch <- m.desc
+	if e.options.CompatGaugeNames && m.legacy != nil {
+		ch <- m.legacy
+	}
+}
+
+func (e *Exporter) storeMetric(ch chan<- prometheus.Metric, value float64, m metric, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(
+		m.desc, m.valueType, value, labels...)
+	if e.options.CompatGaugeNames && m.legacy != nil {
+		ch <- prometheus.MustNewConstMetric(
+			m.legacy, prometheus.GaugeValue, value, labels...)
+	}
 }
 
-func storeMetric(ch chan<- prometheus.Metric, value float64, desc *prometheus.Desc, labels ...string) {
+// storeWrappingCounter stores a 32-bit counter of the Bbox as a 64-bit
+// monotonic counter. The legacy gauge keeps the raw value.
+func (e *Exporter) storeWrappingCounter(ch chan<- prometheus.Metric, raw float64, m metric, labels ...string) {
 	ch <- prometheus.MustNewConstMetric(
-		desc, prometheus.GaugeValue, value, labels...)
+		m.desc, m.valueType, e.counters.extend(m.name, raw, labels...), labels...)
+	if e.options.CompatGaugeNames && m.legacy != nil {
+		ch <- prometheus.MustNewConstMetric(
+			m.legacy, prometheus.GaugeValue, raw, labels...)
+	}
 }
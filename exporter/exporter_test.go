@@ -0,0 +1,247 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+// The responses of testdata/bbox were recorded with --record.dir and
+// --record.key=bbox_exporter, which keeps the fake values of a re-recording.
+const fixturesDir = "testdata/bbox"
+
+// newTestExporter returns an exporter answered by the recorded responses.
+func newTestExporter(t *testing.T, options Options) *Exporter {
+	options.Transport = bbox.NewReplayTransport(fixturesDir)
+	e, err := NewExporter("https://bbox.test", "password", options, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// gatherSamples collects the exporter and returns the samples by series,
+// written as name{label="value",...}.
+func gatherSamples(t *testing.T, e *Exporter) map[string]float64 {
+	return registrySamples(t, e)
+}
+
+// storeFunc is an unchecked collector of the metrics stored by a function.
+type storeFunc func(ch chan<- prometheus.Metric)
+
+func (f storeFunc) Describe(ch chan<- *prometheus.Desc) {}
+
+func (f storeFunc) Collect(ch chan<- prometheus.Metric) { f(ch) }
+
+// storeSamples returns the samples stored by a function, by series. It fails
+// on duplicated series, as the registry of the exporter does.
+func storeSamples(t *testing.T, store func(ch chan<- prometheus.Metric)) map[string]float64 {
+	return registrySamples(t, storeFunc(store))
+}
+
+func registrySamples(t *testing.T, collector prometheus.Collector) map[string]float64 {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.Metric {
+			var labels []string
+			for _, label := range m.Label {
+				labels = append(labels, label.GetName()+"=\""+label.GetValue()+"\"")
+			}
+			sort.Strings(labels)
+			series := family.GetName()
+			if len(labels) > 0 {
+				series += "{" + strings.Join(labels, ",") + "}"
+			}
+			value, ok := sampleValue(m)
+			if !ok {
+				continue
+			}
+			samples[series] = value
+		}
+	}
+	return samples
+}
+
+func TestCollect(t *testing.T) {
+	samples := gatherSamples(t, newTestExporter(t, Options{}))
+	for series, want := range map[string]float64{
+		"bbox_up":                1,
+		"bbox_xdsl_status":       1,
+		"bbox_xdsl_up_fec_total": 120,
+		`bbox_device_capability{capability="xdsl"}`:   1,
+		`bbox_device_capability{capability="ftth"}`:   0,
+		`bbox_lan_connected_devices{link="Ethernet"}`: 1,
+	} {
+		got, ok := samples[series]
+		if !ok {
+			t.Errorf("%s not exported", series)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s = %v, want %v", series, got, want)
+		}
+	}
+	// The host names are redacted in the recorded responses.
+	inSync := map[string]float64{}
+	for series, value := range samples {
+		if strings.HasPrefix(series, "bbox_xdsl_up_bitrate") {
+			t.Errorf("%s exported without --compat.gauge-names", series)
+		}
+		if strings.HasPrefix(series, "bbox_dyndns_in_sync{") {
+			provider := series[strings.Index(series, "provider="):]
+			inSync[strings.TrimSuffix(provider, "}")] = value
+		}
+	}
+	want := map[string]float64{`provider="dyndns"`: 1, `provider="noip"`: 0, `provider="ovh"`: 1}
+	for provider, value := range want {
+		if got, ok := inSync[provider]; !ok || got != value {
+			t.Errorf("bbox_dyndns_in_sync{%s} = %v, want %v", provider, got, value)
+		}
+	}
+}
+
+func TestCollectLite(t *testing.T) {
+	samples := gatherSamples(t, newTestExporter(t, Options{Lite: true}))
+	want := map[string]float64{
+		"bbox_up":                                     1,
+		"bbox_xdsl_status":                            1,
+		"bbox_device_display_luminosity":              100,
+		"bbox_summary_internet_up":                    1,
+		`bbox_lan_connected_devices{link="Ethernet"}`: 1,
+		`bbox_lan_connected_devices{link="Wifi 5"}`:   1,
+	}
+	for series, value := range want {
+		if got, ok := samples[series]; !ok || got != value {
+			t.Errorf("%s = %v, want %v", series, got, value)
+		}
+	}
+	for series := range samples {
+		if strings.HasPrefix(series, "bbox_wan_") || strings.HasPrefix(series, "bbox_wireless_") {
+			t.Errorf("%s exported in lite mode", series)
+		}
+	}
+}
+
+func TestCollectCompatGaugeNames(t *testing.T) {
+	samples := gatherSamples(t, newTestExporter(t, Options{CompatGaugeNames: true}))
+	for _, series := range []string{"bbox_xdsl_up_fec", "bbox_xdsl_up_fec_total", "bbox_xdsl_down_bitrate", `bbox_xdsl_bitrate_bits_per_second{direction="down"}`} {
+		if _, ok := samples[series]; !ok {
+			t.Errorf("%s not exported", series)
+		}
+	}
+}
+
+func TestGatherSnapshot(t *testing.T) {
+	e := newTestExporter(t, Options{SnapshotMaxAge: time.Hour})
+	families, at, err := e.gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) == 0 || at.IsZero() {
+		t.Fatalf("first gather returned %d families at %s, want a scrape", len(families), at)
+	}
+	gatherSamples(t, e)
+	_, _, scraped := e.snapshot.load()
+	if !scraped.After(at) {
+		t.Fatalf("snapshot of %s not updated by a scrape", at)
+	}
+	if _, again, err := e.gather(); err != nil || !again.Equal(scraped) {
+		t.Errorf("gather rendered the scrape of %s, want the snapshot of %s", again, scraped)
+	}
+
+	e.options.SnapshotMaxAge = 0
+	if _, again, err := e.gather(); err != nil || !again.After(scraped) {
+		t.Errorf("gather rendered the scrape of %s, want a new scrape", again)
+	}
+}
+
+// countingTransport counts the requests sent to the Bbox.
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.requests++
+	t.mu.Unlock()
+	return bbox.NewReplayTransport(fixturesDir).RoundTrip(request)
+}
+
+func (t *countingTransport) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.requests
+}
+
+func TestNewExporterInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{
+			name:    "MQTT broker",
+			options: Options{MQTT: MQTTOptions{Broker: "udp://localhost:1883", Interval: time.Minute}},
+		},
+		{
+			name:    "MQTT interval",
+			options: Options{MQTT: MQTTOptions{Broker: "tcp://localhost:1883"}},
+		},
+		{
+			name:    "MQTT in lite mode",
+			options: Options{Lite: true, MQTT: MQTTOptions{Broker: "tcp://localhost:1883", Interval: time.Minute}},
+		},
+		{
+			name:    "presence webhook",
+			options: Options{PresenceWebhook: "ftp://hooks.example.com"},
+		},
+		{
+			name:    "host expiry",
+			options: Options{HostExpiry: -time.Hour},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := &countingTransport{}
+			options := test.options
+			options.Transport = transport
+			options.ThroughputInterval = time.Millisecond
+			if _, err := NewExporter("https://bbox.test", "password", options, log.NewNopLogger()); err == nil {
+				t.Fatal("NewExporter succeeded")
+			}
+			// No throughput sampling is left behind.
+			time.Sleep(20 * time.Millisecond)
+			if n := transport.count(); n != 0 {
+				t.Errorf("%d requests sent to the Bbox", n)
+			}
+		})
+	}
+}
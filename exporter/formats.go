@@ -0,0 +1,234 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+// jsonMetrics is the JSON document returned by JSONHandler. The metrics are
+// sorted by name, and their samples by labels.
+type jsonMetrics struct {
+	Timestamp time.Time    `json:"timestamp"`
+	Metrics   []jsonMetric `json:"metrics"`
+}
+
+type jsonMetric struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Samples []jsonSample `json:"samples"`
+}
+
+type jsonSample struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// snapshotStore keeps the metrics of the last scrape, so the InfluxDB and
+// JSON handlers and the MQTT publisher don't poll the Bbox and advance the
+// trackers again.
+type snapshotStore struct {
+	mu      sync.Mutex
+	metrics []prometheus.Metric
+	// response is the response of the Bbox to the scrape, nil when it failed
+	// or in lite mode.
+	response *bbox.Metrics
+	at       time.Time
+	// refreshMu serializes the scrapes triggered by the handlers.
+	refreshMu sync.Mutex
+}
+
+func (s *snapshotStore) store(metrics []prometheus.Metric, response *bbox.Metrics, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = metrics
+	s.response = response
+	s.at = at
+}
+
+func (s *snapshotStore) load() ([]prometheus.Metric, *bbox.Metrics, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.metrics, s.response, s.at
+}
+
+// snapshotCollector exports the metrics of a snapshot. It is unchecked:
+// the descriptors of the exporter are not described again.
+type snapshotCollector struct {
+	metrics []prometheus.Metric
+}
+
+func (c snapshotCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c snapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+// lastScrape returns the last scrape, with the response of the Bbox, and its
+// time. The Bbox is scraped if the last scrape is not more recent than since.
+func (e *Exporter) lastScrape(since time.Time) ([]prometheus.Metric, *bbox.Metrics, time.Time) {
+	metrics, response, at := e.snapshot.load()
+	if !at.After(since) {
+		e.snapshot.refreshMu.Lock()
+		// Another request may have scraped while waiting.
+		if metrics, response, at = e.snapshot.load(); !at.After(since) {
+			ch := make(chan prometheus.Metric)
+			go func() {
+				e.Collect(ch)
+				close(ch)
+			}()
+			for range ch {
+			}
+			metrics, response, at = e.snapshot.load()
+		}
+		e.snapshot.refreshMu.Unlock()
+	}
+	return metrics, response, at
+}
+
+// gather returns the metrics of the last scrape, and its time. The Bbox is
+// scraped if the last scrape is older than the SnapshotMaxAge option.
+func (e *Exporter) gather() ([]*dto.MetricFamily, time.Time, error) {
+	metrics, _, at := e.lastScrape(time.Now().Add(-e.options.SnapshotMaxAge))
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(snapshotCollector{metrics: metrics}); err != nil {
+		return nil, at, err
+	}
+	families, err := registry.Gather()
+	return families, at, err
+}
+
+// JSONHandler returns the metrics of the Bbox as a JSON document.
+func (e *Exporter) JSONHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families, at, err := e.gather()
+		if err != nil {
+			level.Error(e.logger).Log("msg", "Can't gather metrics", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		document := jsonMetrics{Timestamp: at.UTC(), Metrics: []jsonMetric{}}
+		for _, family := range families {
+			metric := jsonMetric{
+				Name:    family.GetName(),
+				Help:    family.GetHelp(),
+				Type:    strings.ToLower(family.GetType().String()),
+				Samples: []jsonSample{},
+			}
+			for _, m := range family.Metric {
+				value, ok := sampleValue(m)
+				if !ok {
+					continue
+				}
+				labels := map[string]string{}
+				for _, label := range m.Label {
+					labels[label.GetName()] = label.GetValue()
+				}
+				metric.Samples = append(metric.Samples, jsonSample{Labels: labels, Value: value})
+			}
+			document.Metrics = append(document.Metrics, metric)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(document); err != nil {
+			level.Error(e.logger).Log("msg", "Can't encode metrics", "err", err)
+		}
+	})
+}
+
+// InfluxHandler returns the metrics of the Bbox in the InfluxDB line
+// protocol: one measurement per metric, with the labels as tags and the
+// sample in the value field.
+func (e *Exporter) InfluxHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families, at, err := e.gather()
+		if err != nil {
+			level.Error(e.logger).Log("msg", "Can't gather metrics", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		timestamp := strconv.FormatInt(at.UnixNano(), 10)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		out := bufio.NewWriter(w)
+		for _, family := range families {
+			for _, m := range family.Metric {
+				value, ok := sampleValue(m)
+				if !ok {
+					continue
+				}
+				out.WriteString(influxEscape(family.GetName(), ", "))
+				for _, label := range m.Label {
+					if label.GetValue() == "" {
+						// Empty tag values are rejected by InfluxDB.
+						continue
+					}
+					fmt.Fprintf(out, ",%s=%s", influxEscape(label.GetName(), ",= "), influxEscape(label.GetValue(), ",= "))
+				}
+				fmt.Fprintf(out, " value=%s %s\n", strconv.FormatFloat(value, 'g', -1, 64), timestamp)
+			}
+		}
+		if err := out.Flush(); err != nil {
+			level.Error(e.logger).Log("msg", "Can't write metrics", "err", err)
+		}
+	})
+}
+
+// sampleValue returns the value of a gauge, counter or untyped sample. Values
+// which can't be written in JSON or in the line protocol are skipped.
+func sampleValue(m *dto.Metric) (float64, bool) {
+	var value float64
+	switch {
+	case m.Gauge != nil:
+		value = m.Gauge.GetValue()
+	case m.Counter != nil:
+		value = m.Counter.GetValue()
+	case m.Untyped != nil:
+		value = m.Untyped.GetValue()
+	default:
+		return 0, false
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
+
+// influxEscape escapes the given characters, and the backslash, with a backslash.
+func influxEscape(s string, chars string) string {
+	var escaped strings.Builder
+	for _, c := range s {
+		if c == '\\' || strings.ContainsRune(chars, c) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(c)
+	}
+	return escaped.String()
+}
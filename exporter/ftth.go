@@ -0,0 +1,64 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	ftthState              = newGauge("wan_ftth_state", "LinkState of the GEth FTTH port", nil)
+	ftthMode               = newGauge("wan_ftth_mode", "Mode of the FTTH link", []string{"mode"})
+	ftthOpticalTemperature = newGauge("wan_ftth_optical_temperature_celsius", "Temperature of the optical module in °C", nil)
+	ftthOpticalVoltage     = newGauge("wan_ftth_optical_voltage_volts", "Supply voltage of the optical module in V", nil)
+	ftthOpticalBias        = newGauge("wan_ftth_optical_bias_amperes", "Laser bias current of the optical module in A", nil)
+	ftthOpticalTxPower     = newGauge("wan_ftth_optical_tx_power_dbm", "Transmitted optical power in dBm", nil)
+	ftthOpticalRxPower     = newGauge("wan_ftth_optical_rx_power_dbm", "Received optical power in dBm", nil)
+)
+
+func (e *Exporter) describeFtthMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, ftthState)
+	e.describeMetric(ch, ftthMode)
+	e.describeMetric(ch, ftthOpticalTemperature)
+	e.describeMetric(ch, ftthOpticalVoltage)
+	e.describeMetric(ch, ftthOpticalBias)
+	e.describeMetric(ch, ftthOpticalTxPower)
+	e.describeMetric(ch, ftthOpticalRxPower)
+}
+
+func (e *Exporter) storeFtthMetrics(ch chan<- prometheus.Metric, metrics bbox.WanMetrics) {
+	if len(metrics.FtthInformations) > 0 {
+		ftth := metrics.FtthInformations[0].Wan.Ftth
+		ftthStateValue := float64(0)
+		if strings.ToUpper(ftth.State) == "UP" {
+			ftthStateValue = float64(1)
+		}
+		e.storeMetric(ch, ftthStateValue, ftthState)
+		e.storeMetric(ch, 1.0, ftthMode, ftth.Mode)
+	}
+
+	if len(metrics.FtthStatistics) > 0 {
+		stats := metrics.FtthStatistics[0].Wan.Ftth.Stats
+		e.storeMetric(ch, float64(stats.Temperature), ftthOpticalTemperature)
+		e.storeMetric(ch, float64(stats.Voltage), ftthOpticalVoltage)
+		e.storeMetric(ch, float64(stats.Bias)/1000, ftthOpticalBias)
+		e.storeMetric(ch, float64(stats.TxPower), ftthOpticalTxPower)
+		e.storeMetric(ch, float64(stats.RxPower), ftthOpticalRxPower)
+	}
+}
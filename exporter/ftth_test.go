@@ -0,0 +1,67 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+// overlayTransport answers the requests with the responses of dir, and the
+// others with the recorded responses of fixturesDir.
+type overlayTransport struct {
+	dir string
+}
+
+func (t overlayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	resp, err := bbox.NewReplayTransport(t.dir).RoundTrip(request)
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		return resp, err
+	}
+	resp.Body.Close()
+	return bbox.NewReplayTransport(fixturesDir).RoundTrip(request)
+}
+
+func TestStoreFtthMetrics(t *testing.T) {
+	// The line of a fiber Bbox: testdata/ftth.
+	e, err := NewExporter("https://bbox.test", "password", Options{Transport: overlayTransport{dir: "testdata/ftth"}}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := gatherSamples(t, e)
+	for series, want := range map[string]float64{
+		`bbox_wan_ftth_state`:                       1,
+		`bbox_wan_ftth_mode{mode="GPON"}`:           1,
+		`bbox_wan_ftth_optical_temperature_celsius`: 45.5,
+		`bbox_wan_ftth_optical_voltage_volts`:       3.28,
+		`bbox_wan_ftth_optical_bias_amperes`:        0.0125,
+		`bbox_wan_ftth_optical_tx_power_dbm`:        2.1,
+		`bbox_wan_ftth_optical_rx_power_dbm`:        -18.4,
+	} {
+		if got, ok := samples[series]; !ok || got != want {
+			t.Errorf("%s = %v, want %v", series, got, want)
+		}
+	}
+	for series := range samples {
+		if strings.HasPrefix(series, "bbox_xdsl_") {
+			t.Errorf("%s exported for a fiber line", series)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	hotspotBroadcasting = newGauge("hotspot_broadcasting", "Is the SSID of the public hotspot broadcasting", []string{"ssid"})
+	hotspotClients      = newGauge("hotspot_clients", "Number of guest clients associated to the public hotspot", nil)
+	txBytesHotspot      = newCounter("hotspot_transmitted_bytes_total", "TX bytes", nil)
+	txPacketsHotspot    = newCounter("hotspot_transmitted_packets_total", "TX packets", nil)
+	rxBytesHotspot      = newCounter("hotspot_received_bytes_total", "RX bytes", nil)
+	rxPacketsHotspot    = newCounter("hotspot_received_packets_total", "RX packets", nil)
+)
+
+func (e *Exporter) describeHotspotMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, hotspotBroadcasting)
+	e.describeMetric(ch, hotspotClients)
+	e.describeMetric(ch, txBytesHotspot)
+	e.describeMetric(ch, txPacketsHotspot)
+	e.describeMetric(ch, rxBytesHotspot)
+	e.describeMetric(ch, rxPacketsHotspot)
+}
+
+func (e *Exporter) storeHotspotMetrics(ch chan<- prometheus.Metric, metrics bbox.HotspotMetrics) {
+	if len(metrics.Informations) == 0 {
+		return
+	}
+	hotspot := metrics.Informations[0].Hotspot
+	broadcasting := 0.0
+	if bool(hotspot.Enable) && hotspot.Status == 1 {
+		broadcasting = 1.0
+	}
+	e.storeMetric(ch, broadcasting, hotspotBroadcasting, hotspot.SSID)
+	e.storeMetric(ch, float64(len(hotspot.Clients)), hotspotClients)
+	e.storeHotspotTraffic(ch, hotspot.Stats.Tx, txBytesHotspot, txPacketsHotspot)
+	e.storeHotspotTraffic(ch, hotspot.Stats.Rx, rxBytesHotspot, rxPacketsHotspot)
+}
+
+// storeHotspotTraffic stores the traffic counters reported by the firmware.
+func (e *Exporter) storeHotspotTraffic(ch chan<- prometheus.Metric, traffic bbox.HotspotTraffic, bytes metric, packets metric) {
+	if traffic.Bytes != nil {
+		e.storeWrappingCounter(ch, float64(*traffic.Bytes), bytes)
+	}
+	if traffic.Packets != nil {
+		e.storeWrappingCounter(ch, float64(*traffic.Packets), packets)
+	}
+}
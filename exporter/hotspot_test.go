@@ -0,0 +1,62 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+func TestStoreHotspotMetrics(t *testing.T) {
+	samples := gatherSamples(t, newTestExporter(t, Options{}))
+	for series, want := range map[string]float64{
+		`bbox_hotspot_broadcasting{ssid="ssid-4bc60c"}`: 1,
+		`bbox_hotspot_clients`:                          2,
+		`bbox_hotspot_received_bytes_total`:             123456,
+		`bbox_hotspot_received_packets_total`:           1200,
+		`bbox_hotspot_transmitted_bytes_total`:          654321,
+	} {
+		if got, ok := samples[series]; !ok || got != want {
+			t.Errorf("%s = %v, want %v", series, got, want)
+		}
+	}
+	// The firmware doesn't report the transmitted packets.
+	if _, ok := samples["bbox_hotspot_transmitted_packets_total"]; ok {
+		t.Error("bbox_hotspot_transmitted_packets_total exported without a value")
+	}
+}
+
+func TestStoreHotspotMetricsDisabled(t *testing.T) {
+	e := newTestExporter(t, Options{})
+	var metrics bbox.HotspotMetrics
+	metrics.Informations = make([]bbox.HotspotInformations, 1)
+	metrics.Informations[0].Hotspot.SSID = "ssid-4bc60c"
+	metrics.Informations[0].Hotspot.Status = 1
+	samples := storeSamples(t, func(ch chan<- prometheus.Metric) {
+		e.storeHotspotMetrics(ch, metrics)
+	})
+	if got := samples[`bbox_hotspot_broadcasting{ssid="ssid-4bc60c"}`]; got != 0 {
+		t.Errorf("broadcasting = %v for a disabled hotspot, want 0", got)
+	}
+
+	if samples := storeSamples(t, func(ch chan<- prometheus.Metric) {
+		e.storeHotspotMetrics(ch, bbox.HotspotMetrics{})
+	}); len(samples) != 0 {
+		t.Errorf("%d series exported without a hotspot", len(samples))
+	}
+}
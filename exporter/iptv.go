@@ -21,17 +21,13 @@ import (
 )
 
 var (
-	iptvChannel = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "iptv_channel"),
-		"Name of channel",
-		[]string{"name"}, nil,
-	)
+	iptvChannel = newGauge("iptv_channel", "Name of channel", []string{"name"})
 )
 
-func describeIPTVMetrics(ch chan<- *prometheus.Desc) {
-	ch <- iptvChannel
+func (e *Exporter) describeIPTVMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, iptvChannel)
 }
 
-func storeIPTVMetrics(ch chan<- prometheus.Metric, metrics bbox.IPTVMetrics) {
-	// storeMetric(ch, float64(metrics.Informations[0].IPTV[0].Receipt), iptvChannel, metrics.Informations[0].IPTV[0].Name)
+func (e *Exporter) storeIPTVMetrics(ch chan<- prometheus.Metric, metrics bbox.IPTVMetrics) {
+	// e.storeMetric(ch, float64(metrics.Informations[0].IPTV[0].Receipt), iptvChannel, metrics.Informations[0].IPTV[0].Name)
 }
@@ -16,101 +16,64 @@ package exporter
 
 import (
 	"github.com/go-kit/kit/log/level"
-	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/nlamirault/bbox_exporter/bbox"
 )
 
 var (
-	hosts = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "lan_connected_devices"),
-		"Number of devices connected",
-		[]string{"link"}, nil,
-	)
+	hosts = newGauge("lan_connected_devices", "Number of devices connected", []string{"link"})
 
-	txBytesLan = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "lan_transmitted_bytes"),
-		"TX bytes",
-		nil, nil,
-	)
-	txPacketsLan = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "lan_transmitted_packets"),
-		"TX packets",
-		nil, nil,
-	)
-	txPacketsErrorsLan = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "lan_transmitted_packets_errors"),
-		"TX packets in error",
-		nil, nil,
-	)
-	txPacketsDiscardsLan = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "lan_transmitted_packets_discards"),
-		"TX packets discards",
-		nil, nil,
-	)
+	txBytesLan           = newRenamedCounter("lan_transmitted_bytes", "TX bytes", nil)
+	txPacketsLan         = newRenamedCounter("lan_transmitted_packets", "TX packets", nil)
+	txPacketsErrorsLan   = newRenamedCounter("lan_transmitted_packets_errors", "TX packets in error", nil)
+	txPacketsDiscardsLan = newRenamedCounter("lan_transmitted_packets_discards", "TX packets discards", nil)
 
-	rxBytesLan = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "lan_received_bytes"),
-		"RX bytes",
-		nil, nil,
-	)
-	rxPacketsLan = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "lan_received_packets"),
-		"RX packets",
-		nil, nil,
-	)
-	rxPacketsErrorsLan = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "lan_received_packets_errors"),
-		"RX packets in error",
-		nil, nil,
-	)
-	rxPacketsDiscardsLan = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "lan_received_packets_discards"),
-		"RX packets discards",
-		nil, nil,
-	)
+	rxBytesLan           = newRenamedCounter("lan_received_bytes", "RX bytes", nil)
+	rxPacketsLan         = newRenamedCounter("lan_received_packets", "RX packets", nil)
+	rxPacketsErrorsLan   = newRenamedCounter("lan_received_packets_errors", "RX packets in error", nil)
+	rxPacketsDiscardsLan = newRenamedCounter("lan_received_packets_discards", "RX packets discards", nil)
 )
 
-func describeLanMetrics(ch chan<- *prometheus.Desc) {
-	ch <- hosts
-	ch <- txBytesLan
-	ch <- txPacketsLan
-	ch <- txPacketsErrorsLan
-	ch <- txPacketsDiscardsLan
-	ch <- rxBytesLan
-	ch <- rxPacketsLan
-	ch <- rxPacketsErrorsLan
-	ch <- rxPacketsDiscardsLan
+func (e *Exporter) describeLanMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, hosts)
+	e.describeMetric(ch, txBytesLan)
+	e.describeMetric(ch, txPacketsLan)
+	e.describeMetric(ch, txPacketsErrorsLan)
+	e.describeMetric(ch, txPacketsDiscardsLan)
+	e.describeMetric(ch, rxBytesLan)
+	e.describeMetric(ch, rxPacketsLan)
+	e.describeMetric(ch, rxPacketsErrorsLan)
+	e.describeMetric(ch, rxPacketsDiscardsLan)
 }
 
-func storeLanMetrics(logger log.Logger, ch chan<- prometheus.Metric, metrics bbox.LanMetrics) {
-	// storeMetric(ch, float64(len(metrics.Devices[0].Hosts.List)), hosts)
+func (e *Exporter) storeLanMetrics(ch chan<- prometheus.Metric, metrics bbox.LanMetrics) {
+	// e.storeMetric(ch, float64(len(metrics.Devices[0].Hosts.List)), hosts)
 	lanHosts := map[string]int{}
 	if len(metrics.Devices) > 0 {
 		for _, host := range metrics.Devices[0].Hosts.List {
 			// log.Infof("Host: %s, IP: %s %s %s => [%s]", host.Hostname, host.Ipaddress, host.Type, host.Link, host.Active)
-			if host.Active == 1 {
+			if bool(host.Active) {
 				lanHosts[host.Link] = lanHosts[host.Link] + 1
 			}
 		}
 	} else {
-		level.Info(logger).Log("msg", "No LAN devices")
+		level.Info(e.logger).Log("msg", "No LAN devices")
 	}
 	for link, val := range lanHosts {
-		storeMetric(ch, float64(val), hosts, link)
+		e.storeMetric(ch, float64(val), hosts, link)
 	}
 	// log.Infof("%+v", metrics[0].Hosts.List[0])
 	if len(metrics.Statistics) > 0 {
-		storeMetric(ch, float64(metrics.Statistics[0].Lan.Stats.Tx.Bytes), txBytesLan)
-		storeMetric(ch, float64(metrics.Statistics[0].Lan.Stats.Tx.Packets), txPacketsLan)
-		storeMetric(ch, float64(metrics.Statistics[0].Lan.Stats.Tx.Packetserrors), txPacketsErrorsLan)
-		storeMetric(ch, float64(metrics.Statistics[0].Lan.Stats.Tx.Packetsdiscards), txPacketsDiscardsLan)
-		storeMetric(ch, float64(metrics.Statistics[0].Lan.Stats.Rx.Bytes), rxBytesLan)
-		storeMetric(ch, float64(metrics.Statistics[0].Lan.Stats.Rx.Packets), rxPacketsLan)
-		storeMetric(ch, float64(metrics.Statistics[0].Lan.Stats.Rx.Packetserrors), rxPacketsErrorsLan)
-		storeMetric(ch, float64(metrics.Statistics[0].Lan.Stats.Rx.Packetsdiscards), rxPacketsDiscardsLan)
+		e.storeWrappingCounter(ch, float64(metrics.Statistics[0].Lan.Stats.Tx.Bytes), txBytesLan)
+		e.storeWrappingCounter(ch, float64(metrics.Statistics[0].Lan.Stats.Tx.Packets), txPacketsLan)
+		e.storeWrappingCounter(ch, float64(metrics.Statistics[0].Lan.Stats.Tx.Packetserrors), txPacketsErrorsLan)
+		e.storeWrappingCounter(ch, float64(metrics.Statistics[0].Lan.Stats.Tx.Packetsdiscards), txPacketsDiscardsLan)
+		e.storeWrappingCounter(ch, float64(metrics.Statistics[0].Lan.Stats.Rx.Bytes), rxBytesLan)
+		e.storeWrappingCounter(ch, float64(metrics.Statistics[0].Lan.Stats.Rx.Packets), rxPacketsLan)
+		e.storeWrappingCounter(ch, float64(metrics.Statistics[0].Lan.Stats.Rx.Packetserrors), rxPacketsErrorsLan)
+		e.storeWrappingCounter(ch, float64(metrics.Statistics[0].Lan.Stats.Rx.Packetsdiscards), rxPacketsDiscardsLan)
 	} else {
-		level.Warn(logger).Log("msg", "No metrics statistics for LAN")
+		level.Warn(e.logger).Log("msg", "No metrics statistics for LAN")
 	}
 }
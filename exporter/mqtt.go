@@ -0,0 +1,484 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+// mqttTimeout bounds the wait for the broker to acknowledge a message.
+const mqttTimeout = 10 * time.Second
+
+// MQTTOptions configures the publication of the Bbox state to an MQTT broker.
+type MQTTOptions struct {
+	// Broker is the URL of the broker, e.g. tcp://localhost:1883. The
+	// publication is disabled when empty.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// TopicPrefix is the root of the state topics.
+	TopicPrefix string
+	// DiscoveryPrefix is the root of the Home Assistant discovery topics.
+	DiscoveryPrefix string
+	// Interval is the interval between two publications. The last scrape is
+	// published if there was one since the previous publication, the Bbox is
+	// scraped otherwise.
+	Interval time.Duration
+}
+
+// mqttState is the JSON document published on the state topic of the Bbox.
+type mqttState struct {
+	WanUp       string   `json:"wan_up"`
+	Download    *float64 `json:"download,omitempty"`
+	Upload      *float64 `json:"upload,omitempty"`
+	Temperature float64  `json:"temperature"`
+	Hosts       int      `json:"hosts"`
+}
+
+// mqttDiscovery is the configuration of a Home Assistant entity.
+// See: https://www.home-assistant.io/integrations/mqtt/#mqtt-discovery
+type mqttDiscovery struct {
+	Name                string     `json:"name"`
+	UniqueID            string     `json:"unique_id"`
+	StateTopic          string     `json:"state_topic"`
+	ValueTemplate       string     `json:"value_template,omitempty"`
+	AvailabilityTopic   string     `json:"availability_topic"`
+	DeviceClass         string     `json:"device_class,omitempty"`
+	StateClass          string     `json:"state_class,omitempty"`
+	UnitOfMeasurement   string     `json:"unit_of_measurement,omitempty"`
+	PayloadOn           string     `json:"payload_on,omitempty"`
+	PayloadOff          string     `json:"payload_off,omitempty"`
+	JSONAttributesTopic string     `json:"json_attributes_topic,omitempty"`
+	Device              mqttDevice `json:"device"`
+}
+
+type mqttDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// mqttPublisher publishes the state of the Bbox from the scrapes of the
+// exporter, with the Home Assistant discovery configurations of the entities.
+type mqttPublisher struct {
+	exporter   *Exporter
+	options    MQTTOptions
+	client     mqtt.Client
+	throughput *throughputMeter
+
+	mu sync.Mutex
+	// discovered are the discovery topics already published since the
+	// connection to the broker.
+	discovered map[string]bool
+	// hosts are the hosts published to the broker, by object ID.
+	hosts map[string]*mqttHost
+	// published is the time of the last scrape published.
+	published time.Time
+}
+
+// mqttHost tracks a host published to the broker.
+type mqttHost struct {
+	lastSeen time.Time
+	// cleared is set once the retained messages of the expired host are
+	// removed from the broker.
+	cleared bool
+}
+
+// validateMQTTOptions checks the options before connecting to the broker.
+func validateMQTTOptions(options MQTTOptions) error {
+	u, err := url.Parse(options.Broker)
+	if err != nil {
+		return fmt.Errorf("invalid MQTT broker: %s", err)
+	}
+	switch u.Scheme {
+	case "tcp", "ssl", "tls", "ws", "wss", "mqtt", "mqtts":
+	default:
+		return fmt.Errorf("invalid MQTT broker: unsupported scheme %q", u.Scheme)
+	}
+	if options.Interval <= 0 {
+		return fmt.Errorf("invalid MQTT interval: %s", options.Interval)
+	}
+	return nil
+}
+
+func newMQTTPublisher(e *Exporter, options MQTTOptions) *mqttPublisher {
+	p := &mqttPublisher{
+		exporter:   e,
+		options:    options,
+		throughput: newThroughputMeter(),
+		discovered: map[string]bool{},
+		hosts:      map[string]*mqttHost{},
+	}
+	clientOptions := mqtt.NewClientOptions().
+		AddBroker(options.Broker).
+		SetClientID(options.ClientID).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetWill(p.availabilityTopic(), "offline", 1, true).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			level.Warn(e.logger).Log("msg", "MQTT connection lost", "err", err)
+		})
+	p.client = mqtt.NewClient(clientOptions)
+	return p
+}
+
+func (p *mqttPublisher) availabilityTopic() string {
+	return p.options.TopicPrefix + "/availability"
+}
+
+func (p *mqttPublisher) stateTopic() string {
+	return p.options.TopicPrefix + "/state"
+}
+
+func (p *mqttPublisher) hostTopic(mac string) string {
+	return p.options.TopicPrefix + "/hosts/" + mqttObjectID(mac)
+}
+
+// onConnect marks the Bbox available. The discovery configurations are
+// published again, the broker may have lost them.
+func (p *mqttPublisher) onConnect(client mqtt.Client) {
+	level.Info(p.exporter.logger).Log("msg", "Connected to MQTT broker", "broker", p.options.Broker)
+	p.mu.Lock()
+	p.discovered = map[string]bool{}
+	p.mu.Unlock()
+	if err := p.publish(p.availabilityTopic(), "online"); err != nil {
+		level.Error(p.exporter.logger).Log("msg", "Can't publish to MQTT broker", "err", err)
+	}
+}
+
+// run connects to the broker and publishes the state of the Bbox at each
+// interval.
+func (p *mqttPublisher) run() {
+	level.Info(p.exporter.logger).Log("msg", "Publish to MQTT broker", "broker", p.options.Broker, "interval", p.options.Interval)
+	// With the connect retry, the client keeps connecting in the background.
+	p.client.Connect().WaitTimeout(mqttTimeout)
+	ticker := time.NewTicker(p.options.Interval)
+	defer ticker.Stop()
+	for {
+		if p.client.IsConnectionOpen() {
+			if err := p.publishMetrics(); err != nil {
+				level.Error(p.exporter.logger).Log("msg", "Can't publish to MQTT broker", "err", err)
+				p.throughput.reset()
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func (p *mqttPublisher) publishMetrics() error {
+	since := time.Now().Add(-p.options.Interval)
+	if p.published.After(since) {
+		since = p.published
+	}
+	_, metrics, at := p.exporter.lastScrape(since)
+	p.published = at
+	if metrics == nil {
+		return fmt.Errorf("the last scrape of the Bbox failed")
+	}
+	if len(metrics.Device.Informations) == 0 {
+		return fmt.Errorf("no device informations")
+	}
+	device := p.device(metrics.Device.Informations[0])
+	// The publication goes on after an error, the first one is returned.
+	var firstErr error
+	keep := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	keep(p.publishDiscovery(device))
+
+	state := mqttState{
+		WanUp:       "OFF",
+		Temperature: float64(metrics.Device.Informations[0].Device.Temperature.Current),
+	}
+	if len(metrics.Wan.IPInformations) > 0 && metrics.Wan.IPInformations[0].Wan.IP.State == "Up" {
+		state.WanUp = "ON"
+	}
+	if len(metrics.Wan.IPStatistics) > 0 {
+		stats := metrics.Wan.IPStatistics[0].WAN.IP.Stats
+		p.throughput.observe("down", float64(stats.Rx.Bytes), at)
+		p.throughput.observe("up", float64(stats.Tx.Bytes), at)
+		if rate, ok := p.throughput.rate("down"); ok {
+			download := rate / 1e6
+			state.Download = &download
+		}
+		if rate, ok := p.throughput.rate("up"); ok {
+			upload := rate / 1e6
+			state.Upload = &upload
+		}
+	}
+	listed := map[string]bool{}
+	for _, devices := range metrics.Lan.Devices {
+		for _, host := range devices.Hosts.List {
+			if host.Macaddress == "" {
+				continue
+			}
+			id := mqttObjectID(host.Macaddress)
+			listed[id] = true
+			tracked, ok := p.hosts[id]
+			seen, reported := hostLastSeen(host, at)
+			if !ok {
+				tracked = &mqttHost{lastSeen: at}
+				if reported {
+					tracked.lastSeen = seen
+				}
+				p.hosts[id] = tracked
+			}
+			presence := "OFF"
+			if bool(host.Active) {
+				state.Hosts++
+				presence = "ON"
+				tracked.lastSeen = at
+				tracked.cleared = false
+			} else if reported && seen.After(tracked.lastSeen) {
+				tracked.lastSeen = seen
+			}
+			if p.expired(tracked, at) {
+				continue
+			}
+			keep(p.publishHostDiscovery(device, host))
+			keep(p.publish(p.hostTopic(host.Macaddress), presence))
+			attributes, err := json.Marshal(map[string]string{
+				"hostname":   host.Hostname,
+				"ip_address": host.Ipaddress,
+				"mac":        host.Macaddress,
+				"link":       host.Link,
+			})
+			if err != nil {
+				keep(err)
+				continue
+			}
+			keep(p.publish(p.hostTopic(host.Macaddress)+"/attributes", string(attributes)))
+		}
+	}
+	keep(p.expireHosts(device, listed, at))
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	keep(p.publish(p.stateTopic(), string(payload)))
+	return firstErr
+}
+
+// expired returns whether a host was not seen active for the host expiry.
+func (p *mqttPublisher) expired(host *mqttHost, now time.Time) bool {
+	expiry := p.exporter.options.HostExpiry
+	return expiry > 0 && now.Sub(host.lastSeen) > expiry
+}
+
+// expireHosts removes the retained messages of the expired hosts from the
+// broker, so the hosts with randomized MAC addresses don't pile up in Home
+// Assistant. The hosts no longer listed by the Bbox are forgotten once
+// cleared; the others are kept, not to publish them again.
+func (p *mqttPublisher) expireHosts(device mqttDevice, listed map[string]bool, now time.Time) error {
+	var firstErr error
+	for id, host := range p.hosts {
+		if !p.expired(host, now) {
+			continue
+		}
+		if !host.cleared {
+			if err := p.clearHost(device, id); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			host.cleared = true
+		}
+		if !listed[id] {
+			delete(p.hosts, id)
+		}
+	}
+	return firstErr
+}
+
+// clearHost publishes empty retained messages on the topics of a host, which
+// removes its presence sensor from Home Assistant.
+func (p *mqttPublisher) clearHost(device mqttDevice, id string) error {
+	topic := p.configTopic("binary_sensor", device.Identifiers[0], "host_"+id)
+	p.mu.Lock()
+	delete(p.discovered, topic)
+	p.mu.Unlock()
+	for _, t := range []string{topic, p.hostTopic(id), p.hostTopic(id) + "/attributes"} {
+		if err := p.publish(t, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// device describes the Bbox in the discovery configurations.
+func (p *mqttPublisher) device(informations bbox.DeviceInformations) mqttDevice {
+	id := serialHash(informations.Device.SerialNumber)
+	if id == "" {
+		id = mqttObjectID(p.options.TopicPrefix)
+	}
+	return mqttDevice{
+		Identifiers:  []string{"bbox_" + id},
+		Name:         "Bbox",
+		Manufacturer: "Bouygues Telecom",
+		Model:        informations.Device.ModelName,
+		SWVersion:    informations.Device.Running.Version,
+	}
+}
+
+// publishDiscovery publishes the configurations of the sensors of the Bbox.
+func (p *mqttPublisher) publishDiscovery(device mqttDevice) error {
+	id := device.Identifiers[0]
+	sensors := []struct {
+		component string
+		object    string
+		config    mqttDiscovery
+	}{
+		{"binary_sensor", "wan", mqttDiscovery{
+			Name:          "WAN",
+			DeviceClass:   "connectivity",
+			ValueTemplate: "{{ value_json.wan_up }}",
+			PayloadOn:     "ON",
+			PayloadOff:    "OFF",
+		}},
+		{"sensor", "download", mqttDiscovery{
+			Name:              "Download",
+			DeviceClass:       "data_rate",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "Mbit/s",
+			ValueTemplate:     "{{ value_json.download | default(0) | round(2) }}",
+		}},
+		{"sensor", "upload", mqttDiscovery{
+			Name:              "Upload",
+			DeviceClass:       "data_rate",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "Mbit/s",
+			ValueTemplate:     "{{ value_json.upload | default(0) | round(2) }}",
+		}},
+		{"sensor", "temperature", mqttDiscovery{
+			Name:              "Temperature",
+			DeviceClass:       "temperature",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "°C",
+			ValueTemplate:     "{{ value_json.temperature }}",
+		}},
+		{"sensor", "hosts", mqttDiscovery{
+			Name:          "Connected hosts",
+			StateClass:    "measurement",
+			ValueTemplate: "{{ value_json.hosts }}",
+		}},
+	}
+	for _, sensor := range sensors {
+		config := sensor.config
+		config.UniqueID = id + "_" + sensor.object
+		config.StateTopic = p.stateTopic()
+		config.AvailabilityTopic = p.availabilityTopic()
+		config.Device = device
+		if err := p.publishConfig(sensor.component, id, sensor.object, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishHostDiscovery publishes the configuration of the presence sensor of
+// a host.
+func (p *mqttPublisher) publishHostDiscovery(device mqttDevice, host bbox.LanHost) error {
+	id := device.Identifiers[0]
+	object := "host_" + mqttObjectID(host.Macaddress)
+	name := host.Hostname
+	if name == "" {
+		name = host.Macaddress
+	}
+	return p.publishConfig("binary_sensor", id, object, mqttDiscovery{
+		Name:                name,
+		UniqueID:            id + "_" + object,
+		StateTopic:          p.hostTopic(host.Macaddress),
+		AvailabilityTopic:   p.availabilityTopic(),
+		DeviceClass:         "presence",
+		PayloadOn:           "ON",
+		PayloadOff:          "OFF",
+		JSONAttributesTopic: p.hostTopic(host.Macaddress) + "/attributes",
+		Device:              device,
+	})
+}
+
+func (p *mqttPublisher) configTopic(component string, node string, object string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/config", p.options.DiscoveryPrefix, component, node, object)
+}
+
+// publishConfig publishes a discovery configuration, once per connection.
+func (p *mqttPublisher) publishConfig(component string, node string, object string, config mqttDiscovery) error {
+	topic := p.configTopic(component, node, object)
+	p.mu.Lock()
+	discovered := p.discovered[topic]
+	p.discovered[topic] = true
+	p.mu.Unlock()
+	if discovered {
+		return nil
+	}
+	payload, err := json.Marshal(config)
+	if err == nil {
+		err = p.publish(topic, string(payload))
+	}
+	if err != nil {
+		p.mu.Lock()
+		delete(p.discovered, topic)
+		p.mu.Unlock()
+		return fmt.Errorf("can't publish MQTT discovery %s: %s", topic, err)
+	}
+	return nil
+}
+
+// publish sends a retained message, so Home Assistant gets the last state
+// when it starts.
+func (p *mqttPublisher) publish(topic string, payload string) error {
+	token := p.client.Publish(topic, 1, true, payload)
+	if !token.WaitTimeout(mqttTimeout) {
+		return fmt.Errorf("timeout publishing to %s", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("can't publish to %s: %s", topic, err)
+	}
+	return nil
+}
+
+// mqttObjectID returns an identifier usable in a topic and as a Home Assistant
+// object ID.
+func mqttObjectID(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '_'
+		}
+	}, strings.Replace(s, ":", "", -1))
+}
@@ -0,0 +1,135 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build integration
+// +build integration
+
+package exporter
+
+// The tests of the publication to an MQTT broker, run with:
+//
+//	BBOX_EXPORTER_TEST_MQTT_BROKER=tcp://localhost:1883 go test -tags integration ./exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// brokerMessages subscribes to the topics of a publisher, and keeps the last
+// message of each topic.
+type brokerMessages struct {
+	mu       sync.Mutex
+	messages map[string]string
+}
+
+func (b *brokerMessages) handle(client mqtt.Client, message mqtt.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages[message.Topic()] = string(message.Payload())
+}
+
+// wait returns the last message of a topic, waiting for it to be delivered.
+func (b *brokerMessages) wait(topic string) (string, bool) {
+	for deadline := time.Now().Add(mqttTimeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		b.mu.Lock()
+		payload, ok := b.messages[topic]
+		b.mu.Unlock()
+		if ok {
+			return payload, true
+		}
+	}
+	return "", false
+}
+
+func connectTestClient(t *testing.T, client mqtt.Client) {
+	token := client.Connect()
+	if !token.WaitTimeout(mqttTimeout) {
+		t.Fatal("timeout connecting to the broker")
+	}
+	if err := token.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMQTTBroker(t *testing.T) {
+	broker := os.Getenv("BBOX_EXPORTER_TEST_MQTT_BROKER")
+	if broker == "" {
+		t.Skip("BBOX_EXPORTER_TEST_MQTT_BROKER not set")
+	}
+	// The topics of the test, not to mix with the retained messages of
+	// another run.
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	options := MQTTOptions{
+		Broker:          broker,
+		ClientID:        "bbox_exporter_test_" + suffix,
+		TopicPrefix:     "bbox_test_" + suffix,
+		DiscoveryPrefix: "homeassistant_test_" + suffix,
+		Interval:        time.Minute,
+	}
+
+	messages := &brokerMessages{messages: map[string]string{}}
+	subscriber := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID("bbox_exporter_test_subscriber_" + suffix))
+	connectTestClient(t, subscriber)
+	token := subscriber.SubscribeMultiple(map[string]byte{
+		options.TopicPrefix + "/#":     1,
+		options.DiscoveryPrefix + "/#": 1,
+	}, messages.handle)
+	if !token.WaitTimeout(mqttTimeout) || token.Error() != nil {
+		t.Fatalf("can't subscribe: %v", token.Error())
+	}
+	defer subscriber.Disconnect(0)
+
+	p := newMQTTPublisher(newTestExporter(t, Options{}), options)
+	connectTestClient(t, p.client)
+	defer func() {
+		// Remove the retained messages of the test from the broker.
+		messages.mu.Lock()
+		defer messages.mu.Unlock()
+		for topic := range messages.messages {
+			p.publish(topic, "")
+		}
+		p.client.Disconnect(0)
+	}()
+	if err := p.publishMetrics(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := messages.wait(options.TopicPrefix + "/availability"); got != "online" {
+		t.Errorf("availability = %q, want online", got)
+	}
+	payload, ok := messages.wait(options.TopicPrefix + "/state")
+	if !ok {
+		t.Fatal("no state delivered")
+	}
+	var state mqttState
+	if err := json.Unmarshal([]byte(payload), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.WanUp != "ON" || state.Temperature != 62.5 || state.Hosts != 2 {
+		t.Errorf("state = %s", payload)
+	}
+	node := testNode(t, p)
+	if _, ok := messages.wait(options.DiscoveryPrefix + "/sensor/" + node + "/temperature/config"); !ok {
+		t.Error("no discovery delivered")
+	}
+	if got, _ := messages.wait(options.TopicPrefix + "/hosts/022f5088713a"); got != "ON" {
+		t.Errorf("host presence = %q, want ON", got)
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/go-kit/log"
+)
+
+// testClient is an MQTT client keeping the retained messages published, in
+// place of a connection to a broker. See mqtt_integration_test.go for the
+// tests with a broker.
+type testClient struct {
+	mqtt.Client
+	mu       sync.Mutex
+	retained map[string]string
+	// cleared are the topics whose retained message was removed.
+	cleared map[string]bool
+}
+
+// testToken is the token of a completed operation.
+type testToken struct {
+	done chan struct{}
+}
+
+func newTestToken() testToken {
+	done := make(chan struct{})
+	close(done)
+	return testToken{done: done}
+}
+
+func (t testToken) Wait() bool                       { return true }
+func (t testToken) WaitTimeout(d time.Duration) bool { return true }
+func (t testToken) Done() <-chan struct{}            { return t.done }
+func (t testToken) Error() error                     { return nil }
+
+func (c *testClient) IsConnectionOpen() bool { return true }
+
+func (c *testClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	if retained {
+		c.retain(topic, payload.(string))
+	}
+	return newTestToken()
+}
+
+// retain stores a retained message. An empty one removes it.
+func (c *testClient) retain(topic string, payload string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if payload == "" {
+		delete(c.retained, topic)
+		c.cleared[topic] = true
+		return
+	}
+	c.retained[topic] = payload
+	delete(c.cleared, topic)
+}
+
+func (c *testClient) message(topic string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	payload, ok := c.retained[topic]
+	return payload, ok
+}
+
+func (c *testClient) wasCleared(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cleared[topic]
+}
+
+var testMQTTOptions = MQTTOptions{
+	Broker:          "tcp://localhost:1883",
+	ClientID:        "bbox_exporter_test",
+	TopicPrefix:     "bbox",
+	DiscoveryPrefix: "homeassistant",
+	Interval:        time.Minute,
+}
+
+// newTestPublisher returns a publisher of the exporter with a test client,
+// connected.
+func newTestPublisher(e *Exporter) (*mqttPublisher, *testClient) {
+	p := newMQTTPublisher(e, testMQTTOptions)
+	client := &testClient{retained: map[string]string{}, cleared: map[string]bool{}}
+	p.client = client
+	p.onConnect(client)
+	return p, client
+}
+
+func TestMQTTPublish(t *testing.T) {
+	p, broker := newTestPublisher(newTestExporter(t, Options{}))
+	if err := p.publishMetrics(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := broker.message("bbox/availability"); got != "online" {
+		t.Errorf("availability = %q, want online", got)
+	}
+
+	payload, ok := broker.message("bbox/state")
+	if !ok {
+		t.Fatal("no state published")
+	}
+	var state mqttState
+	if err := json.Unmarshal([]byte(payload), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.WanUp != "ON" || state.Temperature != 62.5 || state.Hosts != 2 {
+		t.Errorf("state = %s", payload)
+	}
+	if state.Download != nil || state.Upload != nil {
+		t.Errorf("throughput published after a single poll: %s", payload)
+	}
+
+	node := testNode(t, p)
+	for _, tc := range []struct {
+		topic string
+		want  mqttDiscovery
+	}{
+		{
+			topic: "homeassistant/binary_sensor/" + node + "/wan/config",
+			want: mqttDiscovery{
+				Name:              "WAN",
+				UniqueID:          node + "_wan",
+				StateTopic:        "bbox/state",
+				ValueTemplate:     "{{ value_json.wan_up }}",
+				AvailabilityTopic: "bbox/availability",
+				DeviceClass:       "connectivity",
+				PayloadOn:         "ON",
+				PayloadOff:        "OFF",
+			},
+		},
+		{
+			topic: "homeassistant/sensor/" + node + "/temperature/config",
+			want: mqttDiscovery{
+				Name:              "Temperature",
+				UniqueID:          node + "_temperature",
+				StateTopic:        "bbox/state",
+				ValueTemplate:     "{{ value_json.temperature }}",
+				AvailabilityTopic: "bbox/availability",
+				DeviceClass:       "temperature",
+				StateClass:        "measurement",
+				UnitOfMeasurement: "°C",
+			},
+		},
+		{
+			topic: "homeassistant/binary_sensor/" + node + "/host_022f5088713a/config",
+			want: mqttDiscovery{
+				Name:                "host-7e20c3",
+				UniqueID:            node + "_host_022f5088713a",
+				StateTopic:          "bbox/hosts/022f5088713a",
+				AvailabilityTopic:   "bbox/availability",
+				DeviceClass:         "presence",
+				PayloadOn:           "ON",
+				PayloadOff:          "OFF",
+				JSONAttributesTopic: "bbox/hosts/022f5088713a/attributes",
+			},
+		},
+	} {
+		payload, ok := broker.message(tc.topic)
+		if !ok {
+			t.Errorf("no discovery published on %s", tc.topic)
+			continue
+		}
+		var got mqttDiscovery
+		if err := json.Unmarshal([]byte(payload), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Device.Identifiers[0] != node || got.Device.Model != "Bbox Fast 5330b" {
+			t.Errorf("%s: device = %+v", tc.topic, got.Device)
+		}
+		got.Device = mqttDevice{}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s = %+v, want %+v", tc.topic, got, tc.want)
+		}
+	}
+
+	if got, _ := broker.message("bbox/hosts/022f5088713a"); got != "ON" {
+		t.Errorf("host presence = %q, want ON", got)
+	}
+	payload, _ = broker.message("bbox/hosts/022f5088713a/attributes")
+	var attributes map[string]string
+	if err := json.Unmarshal([]byte(payload), &attributes); err != nil {
+		t.Fatal(err)
+	}
+	if attributes["hostname"] != "host-7e20c3" || attributes["ip_address"] != "198.18.157.207" || attributes["mac"] != "02:2f:50:88:71:3a" {
+		t.Errorf("host attributes = %s", payload)
+	}
+}
+
+func TestMQTTHostExpiry(t *testing.T) {
+	p, broker := newTestPublisher(newTestExporter(t, Options{HostExpiry: time.Hour}))
+	if err := p.publishMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	node := testNode(t, p)
+
+	// A host no longer listed by the Bbox, and a listed host active again.
+	stale := time.Now().Add(-2 * time.Hour)
+	p.hosts["02aabbccddee"] = &mqttHost{lastSeen: stale}
+	p.hosts["022f5088713a"].lastSeen = stale
+	for _, topic := range []string{
+		"homeassistant/binary_sensor/" + node + "/host_02aabbccddee/config",
+		"bbox/hosts/02aabbccddee",
+		"bbox/hosts/02aabbccddee/attributes",
+	} {
+		broker.retain(topic, "OFF")
+	}
+	if err := p.publishMetrics(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, topic := range []string{
+		"homeassistant/binary_sensor/" + node + "/host_02aabbccddee/config",
+		"bbox/hosts/02aabbccddee",
+		"bbox/hosts/02aabbccddee/attributes",
+	} {
+		if !broker.wasCleared(topic) {
+			t.Errorf("%s not cleared", topic)
+		}
+	}
+	if _, ok := p.hosts["02aabbccddee"]; ok {
+		t.Error("expired host still tracked")
+	}
+	if broker.wasCleared("bbox/hosts/022f5088713a") {
+		t.Error("active host cleared")
+	}
+	if got, _ := broker.message("bbox/hosts/022f5088713a"); got != "ON" {
+		t.Errorf("host presence = %q, want ON", got)
+	}
+}
+
+// testNode returns the node ID of the Bbox in the discovery topics.
+func testNode(t *testing.T, p *mqttPublisher) string {
+	_, metrics, _ := p.exporter.snapshot.load()
+	if metrics == nil {
+		t.Fatal("no scrape published")
+	}
+	return p.device(metrics.Device.Informations[0]).Identifiers[0]
+}
+
+func TestMQTTPublishLastScrape(t *testing.T) {
+	transport := &countingTransport{}
+	e, err := NewExporter("https://bbox.test", "password", Options{Transport: transport}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, broker := newTestPublisher(e)
+
+	// The scrape of Prometheus is published, without polling the Bbox.
+	gatherSamples(t, e)
+	requests := transport.count()
+	if err := p.publishMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	if n := transport.count(); n != requests {
+		t.Errorf("%d requests sent to the Bbox to publish the last scrape", n-requests)
+	}
+	if _, ok := broker.message("bbox/state"); !ok {
+		t.Fatal("no state published")
+	}
+
+	// A scrape already published is not published again.
+	if err := p.publishMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	if n := transport.count(); n == requests {
+		t.Error("Bbox not scraped to publish a new state")
+	}
+}
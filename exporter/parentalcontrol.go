@@ -0,0 +1,84 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	parentalControlEnabled   = newGauge("parental_control_enabled", "Is the parental control enabled", nil)
+	parentalControlSchedules = newGauge("parental_control_schedules", "Number of time slots of the parental control rule of a host", []string{"mac"})
+	parentalControlBlocked   = newGauge("parental_control_host_blocked", "Is the internet access of the host blocked by the parental control", []string{"mac", "hostname"})
+	parentalControlRemaining = newGauge("parental_control_host_remaining_seconds", "Time before the parental control changes the access of the host", []string{"mac", "hostname"})
+)
+
+func (e *Exporter) describeParentalControlMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, parentalControlEnabled)
+	e.describeMetric(ch, parentalControlSchedules)
+	e.describeMetric(ch, parentalControlBlocked)
+	e.describeMetric(ch, parentalControlRemaining)
+}
+
+func (e *Exporter) storeParentalControlMetrics(ch chan<- prometheus.Metric, metrics bbox.ParentalControlMetrics, lan bbox.LanMetrics) {
+	if len(metrics.Informations) > 0 {
+		parentalControl := metrics.Informations[0].ParentalControl
+		e.storeMetric(ch, boolValue(bool(parentalControl.Enable)), parentalControlEnabled)
+		seen := map[string]bool{}
+		for _, rule := range parentalControl.List {
+			mac := strings.ToLower(rule.Macaddress)
+			if !bool(rule.Enable) || seen[mac] {
+				continue
+			}
+			seen[mac] = true
+			e.storeMetric(ch, float64(len(rule.Scheduler)), parentalControlSchedules, mac)
+		}
+	}
+
+	if len(lan.Devices) == 0 {
+		return
+	}
+	seen := map[string]bool{}
+	for _, host := range lan.Devices[0].Hosts.List {
+		mac := strings.ToLower(host.Macaddress)
+		if !bool(host.Parentalcontrol.Enable) || seen[mac] {
+			continue
+		}
+		seen[mac] = true
+		blocked := 0.0
+		if parentalControlDenied(host.Parentalcontrol.Status) {
+			blocked = 1.0
+		}
+		e.storeMetric(ch, blocked, parentalControlBlocked, mac, host.Hostname)
+		e.storeMetric(ch, float64(host.Parentalcontrol.StatusRemaining), parentalControlRemaining, mac, host.Hostname)
+	}
+}
+
+// parentalControlDenied returns true if the parental control status of a host
+// denies its internet access. Depending on the firmware, the Bbox reports
+// "Denied", "Deny", "Drop", "Reject" or "Blocked". Any other status, such as
+// "Allowed" or "Accept", allows the access.
+func parentalControlDenied(status string) bool {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "denied", "deny", "drop", "reject", "blocked":
+		return true
+	default:
+		return false
+	}
+}
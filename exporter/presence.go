@@ -0,0 +1,318 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	hostConnects    = newCounter("lan_host_connects_total", "Number of connections of the host seen by the exporter", []string{"mac"})
+	hostSession     = newGauge("lan_host_session_seconds", "Duration of the current connection of the host", []string{"mac"})
+	newHosts        = newCounter("lan_new_hosts_total", "Number of unknown hosts which joined the network", nil)
+	webhookRequests = newCounter("presence_webhook_requests_total", "Number of notifications sent to the presence webhook", []string{"result"})
+)
+
+const (
+	// webhookTimeout bounds the wait for the webhook to accept a notification.
+	webhookTimeout = 10 * time.Second
+	// webhookQueueSize bounds the notifications waiting for the webhook.
+	webhookQueueSize = 100
+)
+
+// firstseenLayout is the layout of the firstseen date of the hosts.
+const firstseenLayout = "2006-01-02T15:04:05-0700"
+
+// presenceEvent is a change of the presence of a host, sent as JSON to the
+// webhook.
+type presenceEvent struct {
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	MAC       string    `json:"mac"`
+	Hostname  string    `json:"hostname"`
+	IPAddress string    `json:"ip_address"`
+	Link      string    `json:"link"`
+}
+
+// The events of presenceEvent.
+const (
+	eventConnect    = "connect"
+	eventDisconnect = "disconnect"
+	eventNewHost    = "new_host"
+)
+
+type hostPresence struct {
+	active   bool
+	since    time.Time
+	lastSeen time.Time
+	connects float64
+}
+
+// presenceTracker diffs the hosts of the Bbox between two polls.
+type presenceTracker struct {
+	mu    sync.Mutex
+	hosts map[string]*hostPresence
+	known map[string]bool
+	// configured are the known hosts given to the tracker, never expired.
+	configured map[string]bool
+	// strict is true when the known hosts are listed: any other host of the
+	// first poll is new.
+	strict bool
+	// expiry is the time after which a host not seen active is forgotten.
+	// Zero keeps the hosts forever.
+	expiry time.Duration
+	polled bool
+	added  float64
+}
+
+// newPresenceTracker returns a tracker where the given MAC addresses are
+// known. Without them, the hosts of the first poll are known. The hosts not
+// seen active for the expiry are forgotten: they are new if they come back.
+func newPresenceTracker(known []string, expiry time.Duration) *presenceTracker {
+	t := &presenceTracker{
+		hosts:      map[string]*hostPresence{},
+		known:      map[string]bool{},
+		configured: map[string]bool{},
+		expiry:     expiry,
+	}
+	for _, mac := range known {
+		t.known[strings.ToLower(mac)] = true
+		t.configured[strings.ToLower(mac)] = true
+	}
+	t.strict = len(known) > 0
+	return t
+}
+
+// observe records the hosts of a poll, and returns the changes since the
+// previous poll. A host missing from the list is disconnected.
+func (t *presenceTracker) observe(hosts []bbox.LanHost, now time.Time) []presenceEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var events []presenceEvent
+	listed := map[string]bool{}
+	for _, host := range hosts {
+		mac := strings.ToLower(host.Macaddress)
+		if mac == "" {
+			continue
+		}
+		active := bool(host.Active)
+		seen, reported := hostLastSeen(host, now)
+		if !active && reported && t.expired(seen, now) && t.hosts[mac] == nil {
+			// Not seen by the Bbox for the expiry: left forgotten.
+			continue
+		}
+		listed[mac] = true
+		event := presenceEvent{
+			Time:      now,
+			MAC:       mac,
+			Hostname:  host.Hostname,
+			IPAddress: host.Ipaddress,
+			Link:      host.Link,
+		}
+		if !t.known[mac] {
+			t.known[mac] = true
+			if t.polled || t.strict {
+				t.added++
+				event.Event = eventNewHost
+				events = append(events, event)
+			}
+		}
+		presence, ok := t.hosts[mac]
+		if !ok {
+			presence = &hostPresence{lastSeen: now}
+			if reported {
+				presence.lastSeen = seen
+			}
+			t.hosts[mac] = presence
+		}
+		if active {
+			presence.lastSeen = now
+		} else if reported && seen.After(presence.lastSeen) {
+			presence.lastSeen = seen
+		}
+		if active && !presence.active {
+			presence.since = now
+			if t.polled {
+				presence.connects++
+				event.Event = eventConnect
+				events = append(events, event)
+			}
+		} else if !active && presence.active && t.polled {
+			event.Event = eventDisconnect
+			events = append(events, event)
+		}
+		presence.active = active
+	}
+	for mac, presence := range t.hosts {
+		if presence.active && !listed[mac] {
+			presence.active = false
+			events = append(events, presenceEvent{Event: eventDisconnect, Time: now, MAC: mac})
+		}
+		if !presence.active && t.expired(presence.lastSeen, now) {
+			delete(t.hosts, mac)
+			if !t.configured[mac] {
+				delete(t.known, mac)
+			}
+		}
+	}
+	t.polled = true
+	return events
+}
+
+// expired returns whether a host last seen at the given time is forgotten.
+func (t *presenceTracker) expired(lastSeen time.Time, now time.Time) bool {
+	return t.expiry > 0 && now.Sub(lastSeen) > t.expiry
+}
+
+// hostLastSeen returns when the Bbox last saw a host: lastseen seconds before
+// now, or else its firstseen date. It returns false when the Bbox reports
+// neither.
+func hostLastSeen(host bbox.LanHost, now time.Time) (time.Time, bool) {
+	if host.Lastseen > 0 {
+		return now.Add(-time.Duration(host.Lastseen) * time.Second), true
+	}
+	if firstseen, err := time.Parse(firstseenLayout, host.Firstseen); err == nil {
+		return firstseen, true
+	}
+	return time.Time{}, false
+}
+
+// requestCounter counts the requests handled by the exporter per result.
+type requestCounter struct {
+	mu     sync.Mutex
+	counts map[string]float64
+}
+
+// newRequestCounter returns a counter exporting the given results from zero.
+func newRequestCounter(results ...string) *requestCounter {
+	counts := make(map[string]float64, len(results))
+	for _, result := range results {
+		counts[result] = 0
+	}
+	return &requestCounter{counts: counts}
+}
+
+func (c *requestCounter) inc(result string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[result]++
+}
+
+func (c *requestCounter) values() map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values := make(map[string]float64, len(c.counts))
+	for result, count := range c.counts {
+		values[result] = count
+	}
+	return values
+}
+
+func (e *Exporter) describePresenceMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, hostConnects)
+	e.describeMetric(ch, hostSession)
+	e.describeMetric(ch, newHosts)
+	if e.options.PresenceWebhook != "" {
+		e.describeMetric(ch, webhookRequests)
+	}
+}
+
+// storePresenceMetrics tracks the hosts of the Bbox, and notifies the
+// webhook of the new hosts.
+func (e *Exporter) storePresenceMetrics(ch chan<- prometheus.Metric, metrics bbox.LanMetrics) {
+	var hosts []bbox.LanHost
+	for _, device := range metrics.Devices {
+		hosts = append(hosts, device.Hosts.List...)
+	}
+	now := time.Now()
+	for _, event := range e.presence.observe(hosts, now) {
+		level.Info(e.logger).Log("msg", "Host presence", "event", event.Event, "mac", event.MAC, "hostname", event.Hostname, "ip", event.IPAddress)
+		if event.Event == eventNewHost && e.options.PresenceWebhook != "" {
+			e.queueWebhook(event)
+		}
+	}
+
+	e.presence.mu.Lock()
+	for mac, presence := range e.presence.hosts {
+		e.storeMetric(ch, presence.connects, hostConnects, mac)
+		if presence.active {
+			e.storeMetric(ch, now.Sub(presence.since).Seconds(), hostSession, mac)
+		}
+	}
+	e.storeMetric(ch, e.presence.added, newHosts)
+	e.presence.mu.Unlock()
+
+	if e.options.PresenceWebhook != "" {
+		for result, count := range e.webhook.values() {
+			e.storeMetric(ch, count, webhookRequests, result)
+		}
+	}
+}
+
+// queueWebhook queues an event for the webhook. The event is dropped when the
+// queue is full, so a slow webhook doesn't pile up the notifications.
+func (e *Exporter) queueWebhook(event presenceEvent) {
+	select {
+	case e.webhookEvents <- event:
+	default:
+		level.Warn(e.logger).Log("msg", "Presence webhook queue full, notification dropped", "mac", event.MAC)
+		e.webhook.inc("dropped")
+	}
+}
+
+// runWebhook sends the queued events to the presence webhook, one at a time.
+func (e *Exporter) runWebhook() {
+	for event := range e.webhookEvents {
+		e.notifyWebhook(event)
+	}
+}
+
+// notifyWebhook posts an event to the presence webhook.
+func (e *Exporter) notifyWebhook(event presenceEvent) {
+	if err := postEvent(e.options.PresenceWebhook, event); err != nil {
+		level.Error(e.logger).Log("msg", "Can't notify presence webhook", "mac", event.MAC, "err", err)
+		e.webhook.inc("error")
+		return
+	}
+	e.webhook.inc("success")
+}
+
+func postEvent(url string, event presenceEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+func lanHost(mac string, active bool) bbox.LanHost {
+	host := bbox.LanHost{Macaddress: mac}
+	if active {
+		host.Active = true
+	}
+	return host
+}
+
+// eventsOf returns the events as "event mac".
+func eventsOf(events []presenceEvent) []string {
+	var result []string
+	for _, event := range events {
+		result = append(result, event.Event+" "+event.MAC)
+	}
+	return result
+}
+
+func TestPresenceTrackerObserve(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	tracker := newPresenceTracker(nil, time.Hour)
+	steps := []struct {
+		hosts []bbox.LanHost
+		after time.Duration
+		want  []string
+	}{
+		{
+			hosts: []bbox.LanHost{lanHost("AA:AA:AA:AA:AA:01", true)},
+		},
+		{
+			hosts: []bbox.LanHost{lanHost("aa:aa:aa:aa:aa:01", true), lanHost("aa:aa:aa:aa:aa:02", true)},
+			after: time.Minute,
+			want:  []string{"new_host aa:aa:aa:aa:aa:02", "connect aa:aa:aa:aa:aa:02"},
+		},
+		{
+			hosts: []bbox.LanHost{lanHost("aa:aa:aa:aa:aa:01", true), lanHost("aa:aa:aa:aa:aa:02", false)},
+			after: 2 * time.Minute,
+			want:  []string{"disconnect aa:aa:aa:aa:aa:02"},
+		},
+		{
+			// Not seen active for more than the expiry: forgotten.
+			hosts: []bbox.LanHost{lanHost("aa:aa:aa:aa:aa:01", true)},
+			after: 2 * time.Hour,
+		},
+		{
+			hosts: []bbox.LanHost{lanHost("aa:aa:aa:aa:aa:01", true), lanHost("aa:aa:aa:aa:aa:02", true)},
+			after: 3 * time.Hour,
+			want:  []string{"new_host aa:aa:aa:aa:aa:02", "connect aa:aa:aa:aa:aa:02"},
+		},
+	}
+	for i, step := range steps {
+		got := eventsOf(tracker.observe(step.hosts, start.Add(step.after)))
+		if len(got) != len(step.want) {
+			t.Errorf("step %d: events = %q, want %q", i, got, step.want)
+			continue
+		}
+		for j := range got {
+			if got[j] != step.want[j] {
+				t.Errorf("step %d: events = %q, want %q", i, got, step.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPresenceTrackerExpiry(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	tracker := newPresenceTracker([]string{"AA:AA:AA:AA:AA:01"}, time.Hour)
+	tracker.observe([]bbox.LanHost{lanHost("aa:aa:aa:aa:aa:01", false), lanHost("aa:aa:aa:aa:aa:02", false)}, start)
+	tracker.observe(nil, start.Add(2*time.Hour))
+	if len(tracker.hosts) != 0 {
+		t.Errorf("hosts = %v, want none after the expiry", tracker.hosts)
+	}
+	if !tracker.known["aa:aa:aa:aa:aa:01"] || tracker.known["aa:aa:aa:aa:aa:02"] {
+		t.Errorf("known = %v, want only the configured host", tracker.known)
+	}
+}
+
+func TestPresenceTrackerLastSeen(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	tracker := newPresenceTracker(nil, time.Hour)
+	seenLongAgo := lanHost("aa:aa:aa:aa:aa:01", false)
+	seenLongAgo.Lastseen = 7200
+	seenRecently := lanHost("aa:aa:aa:aa:aa:02", false)
+	seenRecently.Lastseen = 60
+	firstSeenLongAgo := lanHost("aa:aa:aa:aa:aa:03", false)
+	firstSeenLongAgo.Firstseen = start.Add(-3 * time.Hour).Format(firstseenLayout)
+	tracker.observe([]bbox.LanHost{seenLongAgo, seenRecently, firstSeenLongAgo}, start)
+	if len(tracker.hosts) != 1 || tracker.hosts["aa:aa:aa:aa:aa:02"] == nil {
+		t.Fatalf("hosts = %v, want only the host seen by the Bbox for less than the expiry", tracker.hosts)
+	}
+	if got, want := tracker.hosts["aa:aa:aa:aa:aa:02"].lastSeen, start.Add(-time.Minute); !got.Equal(want) {
+		t.Errorf("last seen = %s, want %s", got, want)
+	}
+
+	// The expired hosts still listed by the Bbox are not new at each poll.
+	for i := 1; i <= 3; i++ {
+		// 35 minutes later.
+		seenLongAgo.Lastseen += 2100
+		seenRecently.Lastseen += 2100
+		if events := tracker.observe([]bbox.LanHost{seenLongAgo, seenRecently, firstSeenLongAgo}, start.Add(time.Duration(i)*35*time.Minute)); len(events) != 0 {
+			t.Errorf("poll %d: events = %q, want none", i, eventsOf(events))
+		}
+	}
+	if len(tracker.hosts) != 0 {
+		t.Errorf("hosts = %v, want none after the expiry", tracker.hosts)
+	}
+}
+
+func TestPresenceWebhookQueue(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}))
+	defer server.Close()
+	e := newTestExporter(t, Options{PresenceWebhook: server.URL})
+
+	// The worker may already post the first event: one or two are dropped.
+	sent := webhookQueueSize + 2
+	for i := 0; i < sent; i++ {
+		e.queueWebhook(presenceEvent{Event: eventNewHost, MAC: "aa:aa:aa:aa:aa:01"})
+	}
+	dropped := e.webhook.values()["dropped"]
+	if dropped < 1 || dropped > 2 {
+		t.Fatalf("%g notifications dropped, want 1 or 2", dropped)
+	}
+	close(release)
+	for deadline := time.Now().Add(10 * time.Second); e.webhook.values()["success"] < float64(sent)-dropped; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%g notifications posted, want %g", e.webhook.values()["success"], float64(sent)-dropped)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if maxRunning != 1 {
+		t.Errorf("%d notifications posted at once, want 1", maxRunning)
+	}
+}
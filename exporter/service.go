@@ -4,7 +4,7 @@
 // you may not use this file except in compliance with the License.
 // You may obtain a copy of the License at
 //
-//     http://www.apache.org/licenses/LICENSE-2.0
+//	http://www.apache.org/licenses/LICENSE-2.0
 //
 // Unless required by applicable law or agreed to in writing, software
 // distributed under the License is distributed on an "AS IS" BASIS,
@@ -20,33 +20,29 @@ import (
 )
 
 var (
-	serviceUp = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "service_status"),
-		"BBox services status",
-		[]string{"name"}, nil,
-	)
+	serviceUp = newGauge("service_status", "BBox services status", []string{"name"})
 )
 
-func describeServicesMetrics(ch chan<- *prometheus.Desc) {
-	ch <- serviceUp
+func (e *Exporter) describeServicesMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, serviceUp)
 }
 
-func storeServicesMetrics(ch chan<- prometheus.Metric, metrics bbox.ServicesMetrics) {
-	storeMetric(ch, float64(metrics.Informations[0].Services.Firewall.Enable), serviceUp, "firewall")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Dyndns.Enable), serviceUp, "dydns")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Dhcp.Enable), serviceUp, "dhcp")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Nat.Enable), serviceUp, "nat")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Gamermode.Enable), serviceUp, "gamermode")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Upnp.Igd.Enable), serviceUp, "upnp")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Remote.Proxywol.Enable), serviceUp, "remote_proxywol")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Remote.Admin.Enable), serviceUp, "remote_admin")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Parentalcontrol.Enable), serviceUp, "parentalcontrol")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Wifischeduler.Enable), serviceUp, "wifischeduler")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Voipscheduler.Enable), serviceUp, "voipscheduler")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Notification.Enable), serviceUp, "notification")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Hotspot.Enable), serviceUp, "hotspot")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Usb.Samba.Enable), serviceUp, "usb_samba")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Usb.Printer.Enable), serviceUp, "usb_printer")
-	storeMetric(ch, float64(metrics.Informations[0].Services.Usb.Dlna.Enable), serviceUp, "user_dlna")
+func (e *Exporter) storeServicesMetrics(ch chan<- prometheus.Metric, metrics bbox.ServicesMetrics) {
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Firewall.Enable)), serviceUp, "firewall")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Dyndns.Enable)), serviceUp, "dydns")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Dhcp.Enable)), serviceUp, "dhcp")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Nat.Enable)), serviceUp, "nat")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Gamermode.Enable)), serviceUp, "gamermode")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Upnp.Igd.Enable)), serviceUp, "upnp")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Remote.Proxywol.Enable)), serviceUp, "remote_proxywol")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Remote.Admin.Enable)), serviceUp, "remote_admin")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Parentalcontrol.Enable)), serviceUp, "parentalcontrol")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Wifischeduler.Enable)), serviceUp, "wifischeduler")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Voipscheduler.Enable)), serviceUp, "voipscheduler")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Notification.Enable)), serviceUp, "notification")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Hotspot.Enable)), serviceUp, "hotspot")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Usb.Samba.Enable)), serviceUp, "usb_samba")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Usb.Printer.Enable)), serviceUp, "usb_printer")
+	e.storeMetric(ch, boolValue(bool(metrics.Informations[0].Services.Usb.Dlna.Enable)), serviceUp, "user_dlna")
 
 }
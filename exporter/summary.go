@@ -0,0 +1,105 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	summaryInternetUp      = newGauge("summary_internet_up", "Is the Bbox connected to the Internet", nil)
+	summaryWanUp           = newGauge("summary_wan_up", "Is the WAN IP link up", nil)
+	summaryWirelessUp      = newGauge("summary_wireless_up", "Is the Wi-Fi up", nil)
+	summaryRadioEnabled    = newGauge("summary_wireless_radio_enabled", "Is the Wi-Fi radio enabled", []string{"frequency"})
+	summaryVoIPUp          = newGauge("summary_voip_up", "Is the VoIP line registered", []string{"line"})
+	summaryVoIPMessages    = newGauge("summary_voip_messages", "Number of voice messages", []string{"line"})
+	summaryVoIPNotAnswered = newGauge("summary_voip_not_answered_calls", "Number of calls not answered", []string{"line"})
+	summaryIPTVReceived    = newGauge("summary_iptv_received_channels", "Number of IPTV channels received", nil)
+)
+
+func (e *Exporter) describeSummaryMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, summaryInternetUp)
+	e.describeMetric(ch, summaryWanUp)
+	e.describeMetric(ch, summaryWirelessUp)
+	e.describeMetric(ch, summaryRadioEnabled)
+	e.describeMetric(ch, summaryVoIPUp)
+	e.describeMetric(ch, summaryVoIPMessages)
+	e.describeMetric(ch, summaryVoIPNotAnswered)
+	e.describeMetric(ch, summaryIPTVReceived)
+}
+
+// collectSummary builds the core metrics from the single /summary request.
+// The metrics of the other endpoints found in the summary are exported under
+// their names; the others are exported as summary_* metrics.
+func (e *Exporter) collectSummary(ch chan<- prometheus.Metric) error {
+	summary, err := e.Bbox.GetSummary()
+	if err != nil {
+		return err
+	}
+	e.storeSummaryMetrics(ch, summary)
+	return nil
+}
+
+func (e *Exporter) storeSummaryMetrics(ch chan<- prometheus.Metric, summary *bbox.SummaryInformations) {
+	e.storeMetric(ch, boolValue(summary.Internet.State == 2), summaryInternetUp)
+	e.storeMetric(ch, boolValue(summary.Wan.IP.State == "Up"), summaryWanUp)
+	e.storeMetric(ch, boolValue(summary.Wireless.Status == 1), summaryWirelessUp)
+	for band, radio := range summary.Wireless.Radio {
+		e.storeMetric(ch, boolValue(bool(radio.Enable)), summaryRadioEnabled, band+"ghz")
+	}
+	lanHosts := map[string]int{}
+	for _, host := range summary.Hosts {
+		if bool(host.Active) {
+			lanHosts[host.Link]++
+		}
+	}
+	for link, val := range lanHosts {
+		e.storeMetric(ch, float64(val), hosts, link)
+	}
+	if xDsl := summary.Wan.XDsl; xDsl != nil {
+		e.storeMetric(ch, boolValue(xDsl.State == "Connected"), xDslStatus)
+	}
+	if ftth := summary.Wan.Ftth; ftth != nil {
+		e.storeMetric(ch, boolValue(strings.ToUpper(ftth.State) == "UP"), ftthState)
+	}
+	e.storeMetric(ch, float64(summary.Display.Luminosity), deviceLuminosity)
+	for _, line := range summary.Voip {
+		id := strconv.Itoa(int(line.ID))
+		e.storeMetric(ch, boolValue(line.Status == "Up"), summaryVoIPUp, id)
+		e.storeMetric(ch, float64(line.Message), summaryVoIPMessages, id)
+		e.storeMetric(ch, float64(line.Notanswered), summaryVoIPNotAnswered, id)
+	}
+	if summary.Iptv != nil {
+		received := 0.0
+		for _, channel := range *summary.Iptv {
+			if channel.Receipt == 1 {
+				received++
+			}
+		}
+		e.storeMetric(ch, received, summaryIPTVReceived)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}
@@ -0,0 +1,110 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+type throughputSample struct {
+	bytes float64
+	at    time.Time
+}
+
+// throughputMeter computes the WAN throughput from the byte counters of
+// two consecutive polls of the Bbox. The counters are extended by its own
+// tracker, so the throughput is kept across a wrap. Each meter has a single
+// source of samples: the samples of the scrapes, of the background sampling
+// and of the MQTT publisher interleave, and would be seen as resets or
+// wraps by a shared tracker.
+type throughputMeter struct {
+	mu       sync.Mutex
+	counters *counterTracker
+	last     map[string]throughputSample
+	rates    map[string]float64
+}
+
+func newThroughputMeter() *throughputMeter {
+	return &throughputMeter{
+		counters: newCounterTracker(),
+		last:     map[string]throughputSample{},
+		rates:    map[string]float64{},
+	}
+}
+
+// observe records the raw byte counter of a direction polled at the given time.
+func (m *throughputMeter) observe(direction string, raw float64, at time.Time) {
+	bytes := m.counters.extend(direction, raw)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous, ok := m.last[direction]
+	m.last[direction] = throughputSample{bytes: bytes, at: at}
+	if !ok || !at.After(previous.at) {
+		return
+	}
+	if bytes < previous.bytes {
+		// Counter reset or reboot: restart the measurement.
+		delete(m.rates, direction)
+		return
+	}
+	m.rates[direction] = (bytes - previous.bytes) * 8 / at.Sub(previous.at).Seconds()
+}
+
+// reset forgets the samples and rates, when the Bbox can't be polled.
+func (m *throughputMeter) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = map[string]throughputSample{}
+	m.rates = map[string]float64{}
+}
+
+// rate returns the last throughput of a direction in bits per second.
+func (m *throughputMeter) rate(direction string) (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rate, ok := m.rates[direction]
+	return rate, ok
+}
+
+// sampleThroughput polls the WAN statistics of the Bbox at the given interval,
+// so the throughput does not depend on the Prometheus scrape interval.
+func (e *Exporter) sampleThroughput(interval time.Duration) {
+	level.Info(e.logger).Log("msg", "Sample WAN throughput", "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := e.Bbox.Authenticate(); err != nil {
+			level.Error(e.logger).Log("msg", "Bbox authentication error", "err", err.Error())
+			e.throughput.reset()
+			continue
+		}
+		stats, err := e.Bbox.GetWanStatistics()
+		if err != nil {
+			level.Error(e.logger).Log("msg", "Bbox API error", "err", err.Error())
+			e.throughput.reset()
+			continue
+		}
+		if len(stats) == 0 {
+			e.throughput.reset()
+			continue
+		}
+		now := time.Now()
+		e.throughput.observe("down", float64(stats[0].WAN.IP.Stats.Rx.Bytes), now)
+		e.throughput.observe("up", float64(stats[0].WAN.IP.Stats.Tx.Bytes), now)
+	}
+}
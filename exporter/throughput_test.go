@@ -0,0 +1,123 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThroughputMeter(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	tests := []struct {
+		name    string
+		samples []float64
+		want    float64
+		ok      bool
+	}{
+		{
+			name:    "single sample",
+			samples: []float64{1000},
+		},
+		{
+			name:    "increasing",
+			samples: []float64{1000, 2000},
+			want:    800,
+			ok:      true,
+		},
+		{
+			name:    "wrap",
+			samples: []float64{counterWrap - 500, 500},
+			want:    800,
+			ok:      true,
+		},
+		{
+			name:    "reset",
+			samples: []float64{1000000, 500},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			meter := newThroughputMeter()
+			for i, sample := range test.samples {
+				meter.observe("down", sample, start.Add(time.Duration(i)*10*time.Second))
+			}
+			rate, ok := meter.rate("down")
+			if ok != test.ok || rate != test.want {
+				t.Errorf("rate = %v, %v, want %v, %v", rate, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestThroughputMeterReset(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	meter := newThroughputMeter()
+	meter.observe("up", 1000, start)
+	meter.observe("up", 2000, start.Add(10*time.Second))
+	meter.reset()
+	if rate, ok := meter.rate("up"); ok {
+		t.Errorf("rate after reset = %v, want none", rate)
+	}
+	meter.observe("up", 3000, start.Add(20*time.Second))
+	if rate, ok := meter.rate("up"); ok {
+		t.Errorf("rate after one sample = %v, want none", rate)
+	}
+}
+
+// The scrapes, the background sampling and the MQTT publisher poll the Bbox
+// concurrently, so their samples interleave out of order across a wrap.
+func TestThroughputInterleavedSources(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	scrape := newCounterTracker()
+	sampler := newThroughputMeter()
+	publisher := newThroughputMeter()
+	samples := []struct {
+		source string
+		raw    float64
+		at     time.Duration
+	}{
+		{"scrape", counterWrap - 3000, 0},
+		{"sampler", counterWrap - 2000, 10 * time.Second},
+		{"sampler", 1000, 20 * time.Second},
+		{"publisher", counterWrap - 1000, 15 * time.Second},
+		{"scrape", 2000, 25 * time.Second},
+		{"publisher", 3000, 35 * time.Second},
+	}
+	var exported []float64
+	for _, sample := range samples {
+		at := start.Add(sample.at)
+		switch sample.source {
+		case "scrape":
+			exported = append(exported, scrape.extend(rxBytesWan.name, sample.raw))
+		case "sampler":
+			sampler.observe("down", sample.raw, at)
+		case "publisher":
+			publisher.observe("down", sample.raw, at)
+		}
+	}
+	if want := []float64{counterWrap - 3000, counterWrap + 2000}; exported[0] != want[0] || exported[1] != want[1] {
+		t.Errorf("exported = %v, want %v", exported, want)
+	}
+	if wraps := scrape.wrapsCount()[rxBytesWan.name]; wraps != 1 {
+		t.Errorf("wraps = %v, want 1", wraps)
+	}
+	if rate, ok := sampler.rate("down"); !ok || rate != 2400 {
+		t.Errorf("sampler rate = %v, %v, want 2400", rate, ok)
+	}
+	if rate, ok := publisher.rate("down"); !ok || rate != 1600 {
+		t.Errorf("publisher rate = %v, %v, want 1600", rate, ok)
+	}
+}
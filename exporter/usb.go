@@ -0,0 +1,85 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	usbPartitionLabels = []string{"device", "id", "label", "fstype"}
+
+	usbDevice           = newGauge("usb_device_info", "USB device plugged on the Bbox", []string{"id", "type", "manufacturer", "product"})
+	usbPartitionSize    = newGauge("usb_partition_size_bytes", "Capacity of the USB partition in bytes", usbPartitionLabels)
+	usbPartitionUsed    = newGauge("usb_partition_used_bytes", "Used space of the USB partition in bytes", usbPartitionLabels)
+	usbPartitionMounted = newGauge("usb_partition_mounted", "Is the USB partition mounted", usbPartitionLabels)
+	usbPrinter          = newGauge("usb_printer_state", "State of the USB printer", []string{"id", "product", "state"})
+)
+
+func (e *Exporter) describeUSBMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, usbDevice)
+	e.describeMetric(ch, usbPartitionSize)
+	e.describeMetric(ch, usbPartitionUsed)
+	e.describeMetric(ch, usbPartitionMounted)
+	e.describeMetric(ch, usbPrinter)
+}
+
+func (e *Exporter) storeUSBMetrics(ch chan<- prometheus.Metric, metrics bbox.USBMetrics) {
+	// A device, partition or printer may be listed twice: only the first
+	// one is kept, as duplicated series would fail the scrape.
+	devices := map[int]bool{}
+	for _, list := range metrics.Devices {
+		for _, device := range list.USB.Devices {
+			if devices[int(device.ID)] {
+				continue
+			}
+			devices[int(device.ID)] = true
+			e.storeMetric(ch, 1.0, usbDevice, strconv.Itoa(int(device.ID)), device.Type, device.Manufacturer, device.Product)
+		}
+	}
+	seen := map[string]bool{}
+	for _, storage := range metrics.Storage {
+		for _, partition := range storage.Storage.Partitions {
+			labels := []string{strconv.Itoa(int(partition.Device)), strconv.Itoa(int(partition.ID)), partition.Label, partition.FSType}
+			key := strings.Join(labels, "/")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			mounted := 0.0
+			if strings.ToLower(partition.State) == "mounted" {
+				mounted = 1.0
+			}
+			e.storeMetric(ch, mounted, usbPartitionMounted, labels...)
+			e.storeMetric(ch, float64(partition.Total), usbPartitionSize, labels...)
+			e.storeMetric(ch, float64(partition.Used), usbPartitionUsed, labels...)
+		}
+	}
+	printers := map[int]bool{}
+	for _, list := range metrics.Printers {
+		for _, printer := range list.Printer {
+			if printers[int(printer.ID)] {
+				continue
+			}
+			printers[int(printer.ID)] = true
+			e.storeMetric(ch, 1.0, usbPrinter, strconv.Itoa(int(printer.ID)), printer.Product, printer.State)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+func TestStoreUSBMetricsDuplicates(t *testing.T) {
+	var metrics bbox.USBMetrics
+	if err := json.Unmarshal([]byte(`{
+		"devices": [{"usb": {"devices": [
+			{"id": 1, "type": "storage", "manufacturer": "SanDisk", "product": "Ultra"},
+			{"id": 1, "type": "storage", "manufacturer": "SanDisk", "product": "Ultra"},
+			{"id": 2, "type": "printer", "manufacturer": "HP", "product": "DeskJet"}
+		]}}],
+		"storage": [{"storage": {"partitions": [
+			{"id": 1, "device": 1, "label": "DATA", "fstype": "vfat", "state": "Mounted", "total": 1000, "used": 400},
+			{"id": 1, "device": 1, "label": "DATA", "fstype": "vfat", "state": "Mounted", "total": 1000, "used": 400}
+		]}}],
+		"printers": [{"printer": [
+			{"id": 2, "product": "DeskJet", "state": "Idle"},
+			{"id": 2, "product": "DeskJet", "state": "Idle"}
+		]}]
+	}`), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	e := &Exporter{}
+	samples := storeSamples(t, func(ch chan<- prometheus.Metric) {
+		e.storeUSBMetrics(ch, metrics)
+	})
+	for series, want := range map[string]float64{
+		`bbox_usb_device_info{id="1",manufacturer="SanDisk",product="Ultra",type="storage"}`: 1,
+		`bbox_usb_device_info{id="2",manufacturer="HP",product="DeskJet",type="printer"}`:    1,
+		`bbox_usb_partition_used_bytes{device="1",fstype="vfat",id="1",label="DATA"}`:        400,
+		`bbox_usb_partition_mounted{device="1",fstype="vfat",id="1",label="DATA"}`:           1,
+		`bbox_usb_printer_state{id="2",product="DeskJet",state="Idle"}`:                      1,
+	} {
+		if got, ok := samples[series]; !ok || got != want {
+			t.Errorf("%s = %v, want %v", series, got, want)
+		}
+	}
+	devices := 0
+	for series := range samples {
+		if strings.HasPrefix(series, "bbox_usb_device_info") {
+			devices++
+		}
+	}
+	if devices != 2 {
+		t.Errorf("%d USB devices exported, want 2", devices)
+	}
+}
@@ -21,76 +21,55 @@ import (
 )
 
 var (
-	txBytesWireless = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "wireless_transmitted_bytes"),
-		"TX bytes",
-		[]string{"frequency"}, nil,
-	)
-	txPacketsWireless = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "wireless_transmitted_packets"),
-		"TX packets",
-		[]string{"frequency"}, nil,
-	)
-	txPacketsErrorsWireless = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "wireless_transmitted_packets_errors"),
-		"TX packets in error",
-		[]string{"frequency"}, nil,
-	)
-	txPacketsDiscardsWireless = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "wireless_transmitted_packets_discards"),
-		"TX packets discards",
-		[]string{"frequency"}, nil,
-	)
+	txBytesWireless           = newRenamedCounter("wireless_transmitted_bytes", "TX bytes", []string{"frequency"})
+	txPacketsWireless         = newRenamedCounter("wireless_transmitted_packets", "TX packets", []string{"frequency"})
+	txPacketsErrorsWireless   = newRenamedCounter("wireless_transmitted_packets_errors", "TX packets in error", []string{"frequency"})
+	txPacketsDiscardsWireless = newRenamedCounter("wireless_transmitted_packets_discards", "TX packets discards", []string{"frequency"})
 
-	rxBytesWireless = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "wireless_received_bytes"),
-		"RX bytes",
-		[]string{"frequency"}, nil,
-	)
-	rxPacketsWireless = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "wireless_received_packets"),
-		"RX packets",
-		[]string{"frequency"}, nil,
-	)
-	rxPacketsErrorsWireless = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "wireless_received_packets_errors"),
-		"RX packets in error",
-		[]string{"frequency"}, nil,
-	)
-	rxPacketsDiscardsWireless = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "", "wireless_received_packets_discards"),
-		"RX packets discards",
-		[]string{"frequency"}, nil,
-	)
+	rxBytesWireless           = newRenamedCounter("wireless_received_bytes", "RX bytes", []string{"frequency"})
+	rxPacketsWireless         = newRenamedCounter("wireless_received_packets", "RX packets", []string{"frequency"})
+	rxPacketsErrorsWireless   = newRenamedCounter("wireless_received_packets_errors", "RX packets in error", []string{"frequency"})
+	rxPacketsDiscardsWireless = newRenamedCounter("wireless_received_packets_discards", "RX packets discards", []string{"frequency"})
 )
 
-func describeWirelessMetrics(ch chan<- *prometheus.Desc) {
-	ch <- hosts
-	ch <- txBytesWireless
-	ch <- txPacketsWireless
-	ch <- txPacketsErrorsWireless
-	ch <- txPacketsDiscardsWireless
-	ch <- rxBytesWireless
-	ch <- rxPacketsWireless
-	ch <- rxPacketsErrorsWireless
-	ch <- rxPacketsDiscardsWireless
+func (e *Exporter) describeWirelessMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, hosts)
+	e.describeMetric(ch, txBytesWireless)
+	e.describeMetric(ch, txPacketsWireless)
+	e.describeMetric(ch, txPacketsErrorsWireless)
+	e.describeMetric(ch, txPacketsDiscardsWireless)
+	e.describeMetric(ch, rxBytesWireless)
+	e.describeMetric(ch, rxPacketsWireless)
+	e.describeMetric(ch, rxPacketsErrorsWireless)
+	e.describeMetric(ch, rxPacketsDiscardsWireless)
 }
 
-func storeWirelessMetrics(ch chan<- prometheus.Metric, metrics bbox.WirelessMetrics) {
-	storeMetric(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Tx.Bytes), txBytesWireless, "5ghz")
-	storeMetric(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Tx.Packets), txPacketsWireless, "5ghz")
-	storeMetric(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Tx.Packetserrors), txPacketsErrorsWireless, "5ghz")
-	storeMetric(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Tx.Packetsdiscards), txPacketsDiscardsWireless, "5ghz")
-	storeMetric(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Rx.Bytes), rxBytesWireless, "5ghz")
-	storeMetric(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Rx.Packets), rxPacketsWireless, "5ghz")
-	storeMetric(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Rx.Packetserrors), rxPacketsErrorsWireless, "5ghz")
-	storeMetric(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Rx.Packetsdiscards), rxPacketsDiscardsWireless, "5ghz")
-	storeMetric(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Tx.Bytes), txBytesWireless, "24ghz")
-	storeMetric(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Tx.Packets), txPacketsWireless, "24ghz")
-	storeMetric(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Tx.Packetserrors), txPacketsErrorsWireless, "24ghz")
-	storeMetric(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Tx.Packetsdiscards), txPacketsDiscardsWireless, "24ghz")
-	storeMetric(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Rx.Bytes), rxBytesWireless, "24ghz")
-	storeMetric(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Rx.Packets), rxPacketsWireless, "24ghz")
-	storeMetric(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Rx.Packetserrors), rxPacketsErrorsWireless, "24ghz")
-	storeMetric(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Rx.Packetsdiscards), rxPacketsDiscardsWireless, "24ghz")
+func (e *Exporter) storeWirelessMetrics(ch chan<- prometheus.Metric, metrics bbox.WirelessMetrics) {
+	e.storeWrappingCounter(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Tx.Bytes), txBytesWireless, "5ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Tx.Packets), txPacketsWireless, "5ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Tx.Packetserrors), txPacketsErrorsWireless, "5ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Tx.Packetsdiscards), txPacketsDiscardsWireless, "5ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Rx.Bytes), rxBytesWireless, "5ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Rx.Packets), rxPacketsWireless, "5ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Rx.Packetserrors), rxPacketsErrorsWireless, "5ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless5GhzStatistics[0].Wireless.SSID.Stats.Rx.Packetsdiscards), rxPacketsDiscardsWireless, "5ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Tx.Bytes), txBytesWireless, "24ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Tx.Packets), txPacketsWireless, "24ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Tx.Packetserrors), txPacketsErrorsWireless, "24ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Tx.Packetsdiscards), txPacketsDiscardsWireless, "24ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Rx.Bytes), rxBytesWireless, "24ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Rx.Packets), rxPacketsWireless, "24ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Rx.Packetserrors), rxPacketsErrorsWireless, "24ghz")
+	e.storeWrappingCounter(ch, float64(metrics.Wireless24GhzStatistics[0].Wireless.SSID.Stats.Rx.Packetsdiscards), rxPacketsDiscardsWireless, "24ghz")
+	if len(metrics.Wireless6GhzStatistics) > 0 {
+		stats := metrics.Wireless6GhzStatistics[0].Wireless.SSID.Stats
+		e.storeWrappingCounter(ch, float64(stats.Tx.Bytes), txBytesWireless, "6ghz")
+		e.storeWrappingCounter(ch, float64(stats.Tx.Packets), txPacketsWireless, "6ghz")
+		e.storeWrappingCounter(ch, float64(stats.Tx.Packetserrors), txPacketsErrorsWireless, "6ghz")
+		e.storeWrappingCounter(ch, float64(stats.Tx.Packetsdiscards), txPacketsDiscardsWireless, "6ghz")
+		e.storeWrappingCounter(ch, float64(stats.Rx.Bytes), rxBytesWireless, "6ghz")
+		e.storeWrappingCounter(ch, float64(stats.Rx.Packets), rxPacketsWireless, "6ghz")
+		e.storeWrappingCounter(ch, float64(stats.Rx.Packetserrors), rxPacketsErrorsWireless, "6ghz")
+		e.storeWrappingCounter(ch, float64(stats.Rx.Packetsdiscards), rxPacketsDiscardsWireless, "6ghz")
+	}
 }
@@ -0,0 +1,131 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	wirelessSchedulerEnabled  = newGauge("wireless_scheduler_enabled", "Is the Wi-Fi scheduler enabled", nil)
+	wirelessSchedulerRadioOff = newGauge("wireless_scheduler_radio_off", "Is the Wi-Fi currently turned off by the scheduler", nil)
+	wirelessWPSEnabled        = newGauge("wireless_wps_enabled", "Is the WPS enabled", nil)
+	wirelessWPSActive         = newGauge("wireless_wps_active", "Is a WPS pairing in progress", nil)
+	wirelessACLEnabled        = newGauge("wireless_acl_enabled", "Is the MAC address filtering of the Wi-Fi enabled", nil)
+	wirelessACLEntries        = newGauge("wireless_acl_entries", "Number of enabled entries of the MAC address filtering", []string{"mode"})
+	wirelessACLMode           = newGauge("wireless_acl_mode", "Mode of the MAC address filtering", []string{"mode"})
+)
+
+func (e *Exporter) describeWirelessAccessMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, wirelessSchedulerEnabled)
+	e.describeMetric(ch, wirelessSchedulerRadioOff)
+	e.describeMetric(ch, wirelessWPSEnabled)
+	e.describeMetric(ch, wirelessWPSActive)
+	e.describeMetric(ch, wirelessACLEnabled)
+	e.describeMetric(ch, wirelessACLEntries)
+	e.describeMetric(ch, wirelessACLMode)
+}
+
+// storeWirelessAccessMetrics stores the access control state of the Wi-Fi.
+// The scheduler slots are evaluated against the clock of the Bbox.
+func (e *Exporter) storeWirelessAccessMetrics(ch chan<- prometheus.Metric, metrics bbox.WirelessAccessControl, now time.Time) {
+	if len(metrics.Scheduler) > 0 {
+		scheduler := metrics.Scheduler[0].Wifischeduler
+		e.storeMetric(ch, boolValue(bool(scheduler.Enable)), wirelessSchedulerEnabled)
+		radioOff := 0.0
+		if bool(scheduler.Enable) {
+			for _, rule := range scheduler.Rules {
+				if rule.Contains(now) {
+					radioOff = 1.0
+					break
+				}
+			}
+		}
+		e.storeMetric(ch, radioOff, wirelessSchedulerRadioOff)
+	}
+
+	if len(metrics.WPS) > 0 {
+		wps := metrics.WPS[0].WPS
+		e.storeMetric(ch, boolValue(bool(wps.Enable)), wirelessWPSEnabled)
+		active := 0.0
+		if wpsActive(wps.Status) {
+			active = 1.0
+		}
+		e.storeMetric(ch, active, wirelessWPSActive)
+	}
+
+	if len(metrics.ACL) > 0 {
+		acl := metrics.ACL[0].ACL
+		mode := strings.ToLower(acl.Mode)
+		entries := 0
+		for _, rule := range acl.Rules {
+			if bool(rule.Enable) {
+				entries++
+			}
+		}
+		e.storeMetric(ch, boolValue(bool(acl.Enable)), wirelessACLEnabled)
+		e.storeMetric(ch, float64(entries), wirelessACLEntries, mode)
+		e.storeMetric(ch, 1.0, wirelessACLMode, mode)
+	}
+}
+
+// wpsActive returns true if the WPS status reports a pairing in progress.
+func wpsActive(status string) bool {
+	switch strings.ToLower(status) {
+	case "inprogress", "in_progress", "active", "started", "running":
+		return true
+	default:
+		return false
+	}
+}
+
+// deviceTime returns the clock of the Bbox, or the local clock if the Bbox
+// does not report it.
+func deviceTime(metrics bbox.DeviceMetrics) time.Time {
+	if len(metrics.Informations) > 0 {
+		if now, err := metrics.Informations[0].Time(); err == nil {
+			return now
+		}
+	}
+	return time.Now()
+}
+
+// WirelessInventoryHandler returns the scheduler, WPS and ACL settings of the
+// Wi-Fi as JSON.
+func (e *Exporter) WirelessInventoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := e.Bbox.Authenticate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		inventory, err := e.Bbox.GetWirelessAccessControl()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inventory); err != nil {
+			level.Error(e.logger).Log("msg", "Can't encode Wi-Fi inventory", "err", err)
+		}
+	})
+}
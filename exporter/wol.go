@@ -0,0 +1,82 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+// WakeOnLANHandler sends a Wake-on-LAN to the host of the mac parameter,
+// through the Bbox. Only POST requests are accepted. With the dry_run
+// parameter, it only checks that the host is known by the Bbox.
+func (e *Exporter) WakeOnLANHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		dryRun, _ := strconv.ParseBool(r.FormValue("dry_run"))
+		entry := auditEntry{Action: "wol", Target: r.FormValue("mac"), DryRun: dryRun}
+		result, status, err := e.wakeOnLAN(entry.Target, dryRun)
+		entry.Result = result
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		e.auditAction(r, &entry)
+		e.writeAction(w, status, entry)
+	})
+}
+
+// wakeOnLAN sends the Wake-on-LAN and returns its result, with the HTTP
+// status of the response.
+func (e *Exporter) wakeOnLAN(mac string, dryRun bool) (string, int, error) {
+	hardwareAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return "invalid", http.StatusBadRequest, err
+	}
+	if err := e.Bbox.Authenticate(); err != nil {
+		return "error", http.StatusBadGateway, err
+	}
+	if dryRun {
+		err = e.Bbox.CheckLanHost(hardwareAddr.String())
+	} else {
+		now := time.Now()
+		if !e.actions.allow("wol", hardwareAddr.String(), now) {
+			return "rate_limited", http.StatusTooManyRequests, errRateLimited
+		}
+		if err = e.Bbox.WakeOnLAN(hardwareAddr.String()); err != nil {
+			e.actions.release("wol", hardwareAddr.String(), now)
+		}
+	}
+	switch {
+	case err == nil && dryRun:
+		return "dry_run", http.StatusOK, nil
+	case err == nil:
+		return "success", http.StatusOK, nil
+	case errors.Is(err, bbox.ErrUnknownHost):
+		return "unknown_host", http.StatusNotFound, err
+	case errors.Is(err, bbox.ErrNotSupported):
+		return "not_supported", http.StatusNotImplemented, err
+	default:
+		return "error", http.StatusBadGateway, err
+	}
+}
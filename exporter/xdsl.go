@@ -0,0 +1,164 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nlamirault/bbox_exporter/bbox"
+)
+
+var (
+	xDslLocalFEC    = newRenamedCounter("xdsl_down_fec", "Number of FEC errors for downstream", nil)
+	xDslRemoteFEC   = newRenamedCounter("xdsl_up_fec", "Number of FEC errors for upstream", nil)
+	xDslLocalCRC    = newRenamedCounter("xdsl_down_crc", "Number of CRC errors for downstream", nil)
+	xDslRemoteCRC   = newRenamedCounter("xdsl_up_crc", "Number of CRC errors for upstream", nil)
+	xDslLocalHEC    = newRenamedCounter("xdsl_down_hec", "Number of HEC errors for downstream", nil)
+	xDslRemoteHEC   = newRenamedCounter("xdsl_up_hec", "Number of HEC errors for upstream", nil)
+	xDslStatus      = newGauge("xdsl_status", "Status of the xDsl connexion", nil)
+	xDslModulation  = newGauge("xdsl_modulation", "Modulation of the xDsl link", []string{"modulation"})
+	xDslShowtime    = newGauge("xdsl_showtime", "Active time of the xDsl link in seconds", nil)
+	xDslATUR        = newGauge("xdsl_atur", "Provider of the ATU-R chipset", []string{"provider"})
+	xDslATUC        = newGauge("xdsl_atuc", "Provider of the ATU-C chupset", []string{"provider"})
+	xDslSyncCount   = newGauge("xdsl_sync_count", "Number of xDsl synchronisations  since last reboot", nil)
+	xDslBitrate     = newGauge("xdsl_bitrate_bits_per_second", "Synchronised speed of the xDsl line in bit/s", []string{"direction"})
+	xDslSNRMargin   = newGauge("xdsl_snr_margin_db", "Signal to noise ratio margin of the xDsl line in dB", []string{"direction"})
+	xDslAttenuation = newGauge("xdsl_attenuation_db", "Attenuation of the xDsl line in dB", []string{"direction"})
+	xDslPower       = newGauge("xdsl_power_dbm", "Output power of the xDsl line in dBm", []string{"direction"})
+	xDslBoost       = newGauge("xdsl_boost", "Indicates which speed boosting technology is used", []string{"direction", "used"})
+	xDslInterleave  = newGauge("xdsl_interleave_delay", "Interleave delay of the xDsl line (unit unknown)", []string{"direction"})
+
+	// xDslLegacy are the per-direction gauges of previous versions of the
+	// exporter, in the units of the Bbox API, exported with --compat.gauge-names.
+	xDslLegacy = map[string]xDslLegacyMetrics{
+		"up":   newXDslLegacyMetrics("up", "upstream"),
+		"down": newXDslLegacyMetrics("down", "downstream"),
+	}
+)
+
+type xDslLegacyMetrics struct {
+	bitrate     metric
+	noise       metric
+	attenuation metric
+	power       metric
+	boost       metric
+	interleave  metric
+}
+
+func newXDslLegacyMetrics(direction string, stream string) xDslLegacyMetrics {
+	prefix := "xdsl_" + direction + "_"
+	return xDslLegacyMetrics{
+		bitrate:     newGauge(prefix+"bitrate", "Speed of the xDsl "+stream+" in kB/s (deprecated)", nil),
+		noise:       newGauge(prefix+"noise", "Noise of the xDsl "+stream+" in cB (deprecated)", nil),
+		attenuation: newGauge(prefix+"attenuation", "Attenuation of the xDsl "+stream+" in cB (deprecated)", nil),
+		power:       newGauge(prefix+"power", "Power of the xDsl "+stream+" in cB (deprecated)", nil),
+		boost:       newGauge(prefix+"boost", "Indicates which speed boosting technology is used for the "+stream+" (deprecated)", []string{"used"}),
+		interleave:  newGauge(prefix+"interleave", "Interleave delay of the xDsl "+stream+" (deprecated)", nil),
+	}
+}
+
+func (e *Exporter) describeXDslMetrics(ch chan<- *prometheus.Desc) {
+	e.describeMetric(ch, xDslLocalFEC)
+	e.describeMetric(ch, xDslRemoteFEC)
+	e.describeMetric(ch, xDslLocalCRC)
+	e.describeMetric(ch, xDslRemoteCRC)
+	e.describeMetric(ch, xDslLocalHEC)
+	e.describeMetric(ch, xDslRemoteHEC)
+	e.describeMetric(ch, xDslStatus)
+	e.describeMetric(ch, xDslModulation)
+	e.describeMetric(ch, xDslShowtime)
+	e.describeMetric(ch, xDslATUR)
+	e.describeMetric(ch, xDslATUC)
+	e.describeMetric(ch, xDslSyncCount)
+	e.describeMetric(ch, xDslBitrate)
+	e.describeMetric(ch, xDslSNRMargin)
+	e.describeMetric(ch, xDslAttenuation)
+	e.describeMetric(ch, xDslPower)
+	e.describeMetric(ch, xDslBoost)
+	e.describeMetric(ch, xDslInterleave)
+	if e.options.CompatGaugeNames {
+		for _, legacy := range xDslLegacy {
+			e.describeMetric(ch, legacy.bitrate)
+			e.describeMetric(ch, legacy.noise)
+			e.describeMetric(ch, legacy.attenuation)
+			e.describeMetric(ch, legacy.power)
+			e.describeMetric(ch, legacy.boost)
+			e.describeMetric(ch, legacy.interleave)
+		}
+	}
+}
+
+func (e *Exporter) storeXDslMetrics(ch chan<- prometheus.Metric, metrics bbox.WanMetrics) {
+	if metrics.IsFtth() {
+		return
+	}
+
+	if len(metrics.XDslStatistics) > 0 {
+		stats := metrics.XDslStatistics[0].Wan.XDsl.Stats
+		e.storeMetric(ch, float64(stats.LocalFEC), xDslLocalFEC)
+		e.storeMetric(ch, float64(stats.RemoteFEC), xDslRemoteFEC)
+		e.storeMetric(ch, float64(stats.LocalCRC), xDslLocalCRC)
+		e.storeMetric(ch, float64(stats.RemoteCRC), xDslRemoteCRC)
+		e.storeMetric(ch, float64(stats.LocalHEC), xDslLocalHEC)
+		e.storeMetric(ch, float64(stats.RemoteHEC), xDslRemoteHEC)
+	} else {
+		level.Warn(e.logger).Log("msg", "No xDsl statistics")
+	}
+
+	if len(metrics.XDslInformations) == 0 {
+		level.Warn(e.logger).Log("msg", "No xDsl informations")
+		return
+	}
+	xDsl := metrics.XDslInformations[0].Wan.XDsl
+	if xDsl.State == "Connected" {
+		e.storeMetric(ch, 1.0, xDslStatus)
+	} else {
+		e.storeMetric(ch, 0.0, xDslStatus)
+	}
+	e.storeMetric(ch, 1.0, xDslModulation, xDsl.Modulation)
+	e.storeMetric(ch, float64(xDsl.Showtime), xDslShowtime)
+	e.storeMetric(ch, 1.0, xDslATUR, xDsl.ATURProvider)
+	e.storeMetric(ch, 1.0, xDslATUC, xDsl.ATUCProcider)
+	e.storeMetric(ch, float64(xDsl.SyncCount), xDslSyncCount)
+	e.storeXDslDirectionMetrics(ch, "up", xDsl.Up)
+	e.storeXDslDirectionMetrics(ch, "down", xDsl.Down)
+}
+
+// storeXDslDirectionMetrics stores one direction of the line. The Bbox API
+// reports the bitrate in kbit/s, and noise, attenuation and power in tenths
+// of dB.
+func (e *Exporter) storeXDslDirectionMetrics(ch chan<- prometheus.Metric, direction string, metrics bbox.XDslDirection) {
+	e.storeMetric(ch, float64(metrics.Biterates)*1000, xDslBitrate, direction)
+	e.storeMetric(ch, float64(metrics.Noise)/10, xDslSNRMargin, direction)
+	e.storeMetric(ch, float64(metrics.Attenuation)/10, xDslAttenuation, direction)
+	e.storeMetric(ch, float64(metrics.Power)/10, xDslPower, direction)
+	e.storeMetric(ch, float64(metrics.PhyR), xDslBoost, direction, "phyr")
+	e.storeMetric(ch, float64(metrics.GINP), xDslBoost, direction, "ginp")
+	e.storeMetric(ch, float64(metrics.Nitro), xDslBoost, direction, "nitro")
+	e.storeMetric(ch, float64(metrics.InterleaveDelay), xDslInterleave, direction)
+
+	if e.options.CompatGaugeNames {
+		legacy := xDslLegacy[direction]
+		e.storeMetric(ch, float64(metrics.Biterates), legacy.bitrate)
+		e.storeMetric(ch, float64(metrics.Noise), legacy.noise)
+		e.storeMetric(ch, float64(metrics.Attenuation), legacy.attenuation)
+		e.storeMetric(ch, float64(metrics.Power), legacy.power)
+		e.storeMetric(ch, float64(metrics.PhyR), legacy.boost, "phyr")
+		e.storeMetric(ch, float64(metrics.GINP), legacy.boost, "ginp")
+		e.storeMetric(ch, float64(metrics.Nitro), legacy.boost, "nitro")
+		e.storeMetric(ch, float64(metrics.InterleaveDelay), legacy.interleave)
+	}
+}
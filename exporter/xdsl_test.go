@@ -0,0 +1,43 @@
+// Copyright (C) 2021 Nicolas Lamirault <nicolas.lamirault@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+)
+
+func TestStoreXDslMetrics(t *testing.T) {
+	samples := gatherSamples(t, newTestExporter(t, Options{CompatGaugeNames: true}))
+	// The line of testdata/bbox/wan_xdsl.json, in kbit/s and tenths of dB.
+	for series, want := range map[string]float64{
+		`bbox_xdsl_bitrate_bits_per_second{direction="down"}`: 48000000,
+		`bbox_xdsl_bitrate_bits_per_second{direction="up"}`:   9000000,
+		`bbox_xdsl_snr_margin_db{direction="down"}`:           6.1,
+		`bbox_xdsl_snr_margin_db{direction="up"}`:             8.5,
+		`bbox_xdsl_attenuation_db{direction="down"}`:          14.5,
+		`bbox_xdsl_attenuation_db{direction="up"}`:            12.3,
+		`bbox_xdsl_power_dbm{direction="down"}`:               14.5,
+		`bbox_xdsl_power_dbm{direction="up"}`:                 7.2,
+		// The legacy gauges keep the units of the Bbox API.
+		`bbox_xdsl_down_bitrate`:   48000,
+		`bbox_xdsl_down_noise`:     61,
+		`bbox_xdsl_up_attenuation`: 123,
+		`bbox_xdsl_up_power`:       72,
+	} {
+		if got, ok := samples[series]; !ok || got != want {
+			t.Errorf("%s = %v, want %v", series, got, want)
+		}
+	}
+}